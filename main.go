@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/fjarm/infrastructure/pkg/v1/certmanager"
+	"github.com/fjarm/infrastructure/pkg/v1/dragonfly"
 	"github.com/fjarm/infrastructure/pkg/v1/valkey"
 	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
@@ -27,11 +28,40 @@ func main() {
 			return err
 		}
 
-		_, err = valkey.DeployValkeyCluster(
-			ctx,
-			k8sProvider,
-			certManagerDeps,
-		)
-		return err
+		valkeyInstances, err := valkey.NewInstanceConfigsFromStackConfig(ctx)
+		if err != nil {
+			return err
+		}
+		var dragonflyDeps []pulumi.Resource
+		if valkey.UsesBackend(valkeyInstances, valkey.BackendDragonfly) {
+			dragonflyDeps, err = dragonfly.DeployDragonflyOperator(ctx, k8sProvider, certManagerDeps)
+			if err != nil {
+				return err
+			}
+		}
+		for _, instance := range valkeyInstances {
+			switch instance.Backend {
+			case valkey.BackendDragonfly:
+				_, err = dragonfly.DeployDragonflyInstance(
+					ctx,
+					k8sProvider,
+					instance.Name,
+					&instance.InstanceSpec,
+					append(certManagerDeps, dragonflyDeps...),
+				)
+			default:
+				_, err = valkey.DeployValkeyCluster(
+					ctx,
+					k8sProvider,
+					instance.Name,
+					&instance.InstanceSpec,
+					pulumi.DependsOn(certManagerDeps),
+				)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 }
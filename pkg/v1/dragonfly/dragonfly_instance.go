@@ -0,0 +1,293 @@
+package dragonfly
+
+import (
+	"fmt"
+	"github.com/fjarm/infrastructure/pkg/v1/internal/valkey"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"strings"
+)
+
+// ErrUnsupportedSetting is returned when an instance spec uses a setting the Dragonfly backend can't honour.
+var ErrUnsupportedSetting = fmt.Errorf("setting not supported by dragonfly")
+
+const (
+	authPasswordKey        = "password"
+	authReplicaPasswordKey = "replica-password"
+	image                  = "docker.dragonflydb.io/dragonflydb/dragonfly:v1.30.3"
+	// minMemoryPerThread is the smallest `maxmemory` Dragonfly accepts per proactor thread.
+	minMemoryPerThread = 256 << 20
+	replicaUsername    = "replica-user"
+	snapshotSchedule   = "*/5 * * * *"
+)
+
+// DeployDragonflyInstance deploys the instance called [name] as a `Dragonfly` resource reconciled by the operator
+// deployed with [DeployDragonflyOperator]. The namespace, cert-manager certificate, ACL file, consumer credentials, and
+// client certificates are the ones of a Valkey instance, so that consumers can't tell the backends apart.
+func DeployDragonflyInstance(
+	ctx *pulumi.Context,
+	provider *kubernetes.Provider,
+	name string,
+	spec *valkey.InstanceSpec,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	err := validate(spec)
+	if err != nil {
+		return nil, fmt.Errorf("dragonfly instance %s: %w", name, err)
+	}
+	namespace, err := valkey.DeployInstanceNamespace(ctx, name, spec, provider, []pulumi.Resource{})
+	if err != nil {
+		return nil, err
+	}
+	cert, err := valkey.DeployInstanceCertificate(ctx, name, namespace, spec, provider, append(deps, namespace))
+	if err != nil {
+		return nil, err
+	}
+	aclSecret, err := valkey.DeployInstanceACLSecret(ctx, name, namespace, spec, provider, append(deps, namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	authSecret, err := corev1.NewSecret(
+		ctx,
+		authSecretName(name),
+		&corev1.SecretArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(authSecretName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newDragonflyLabels(name),
+			},
+			Type: pulumi.String("Opaque"),
+			StringData: pulumi.ToSecret(pulumi.StringMap{
+				authPasswordKey:        pulumi.String(spec.DefaultUserCredentials),
+				authReplicaPasswordKey: pulumi.String(spec.ReplicaUserCredentials),
+			}).(pulumi.StringMapOutput),
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(append(deps, namespace)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := newDragonflyArgs(ctx, name, namespace, spec)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := apiextensions.NewCustomResource(
+		ctx,
+		name,
+		args,
+		pulumi.Provider(provider),
+		pulumi.DependsOn(append(deps, namespace, cert, aclSecret, authSecret)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	consumers, err := valkey.DeployInstanceConsumers(ctx, name, spec, provider, append(deps, aclSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	err = valkey.ExportInstanceOutputs(ctx, name, spec, valkey.NewConnectionInfo(name, spec))
+	if err != nil {
+		return nil, err
+	}
+	return append([]pulumi.Resource{namespace, cert, aclSecret, authSecret, instance}, consumers...), nil
+}
+
+// validate checks the shared instance spec and rejects the settings Dragonfly has no equivalent for. The operator
+// fails over on its own, so sentinel settings are ignored.
+func validate(spec *valkey.InstanceSpec) error {
+	err := spec.Validate()
+	if err != nil {
+		return err
+	}
+	switch {
+	case spec.Backend != valkey.BackendDragonfly:
+		return fmt.Errorf("%w: %s instances are deployed by their own package", valkey.ErrUnknownBackend, spec.Backend)
+	case spec.Mode != valkey.ModeReplication:
+		return fmt.Errorf("%w: mode %s", ErrUnsupportedSetting, spec.Mode)
+	case spec.Persistence.AOFEnabled():
+		return fmt.Errorf("%w: the append only file", ErrUnsupportedSetting)
+	case spec.Backup.Enabled || spec.Restore.Snapshot != "":
+		return fmt.Errorf("%w: backups and restores", ErrUnsupportedSetting)
+	case spec.Functions.Directory != "":
+		return fmt.Errorf("%w: function libraries", ErrUnsupportedSetting)
+	case spec.Metrics.Enabled:
+		return fmt.Errorf("%w: the metrics exporter", ErrUnsupportedSetting)
+	case len(spec.Modules) > 0:
+		return fmt.Errorf("%w: modules, dragonfly implements their commands natively", ErrUnsupportedSetting)
+	case spec.Proxy.Enabled:
+		return fmt.Errorf("%w: the connection-pooling proxy", ErrUnsupportedSetting)
+	case spec.Benchmark.Enabled:
+		return fmt.Errorf("%w: the benchmark job", ErrUnsupportedSetting)
+	case spec.Expose.Type != valkey.ExposureNone:
+		return fmt.Errorf("%w: external exposure", ErrUnsupportedSetting)
+	case len(spec.AllowedClients) > 0:
+		return fmt.Errorf("%w: allowedClients, the operator doesn't enforce them", ErrUnsupportedSetting)
+	case spec.TLS.CertificateUsers:
+		return fmt.Errorf("%w: certificate users", ErrUnsupportedSetting)
+	case strings.HasPrefix(spec.Sizing.MaxMemoryPolicy, "volatile-"):
+		return fmt.Errorf("%w: maxMemoryPolicy %s", ErrUnsupportedSetting, spec.Sizing.MaxMemoryPolicy)
+	}
+
+	maxMemory, err := spec.Sizing.MaxMemory()
+	if err != nil {
+		return err
+	}
+	threads, err := proactorThreads(spec.Sizing.ValkeyResources().CPULimit)
+	if err != nil {
+		return err
+	}
+	if maxMemory < int64(threads)*minMemoryPerThread {
+		return fmt.Errorf("%w: maxmemory must be at least 256Mi per CPU", ErrUnsupportedSetting)
+	}
+	return nil
+}
+
+// newDragonflyArgs returns the `Dragonfly` resource of an instance. The server certificate is the instance's
+// cert-manager certificate, the ACL file is the one rendered for Valkey, and replicas authenticate to the primary as
+// the replica user. The pods are annotated with the checksum of the ACL file, so that password rotation phases take
+// effect through a rolling restart.
+func newDragonflyArgs(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *valkey.InstanceSpec,
+) (*apiextensions.CustomResourceArgs, error) {
+	maxMemory, err := spec.Sizing.MaxMemory()
+	if err != nil {
+		return nil, err
+	}
+	resources := spec.Sizing.ValkeyResources()
+	threads, err := proactorThreads(resources.CPULimit)
+	if err != nil {
+		return nil, err
+	}
+	if config.GetBool(ctx, configKind) {
+		threads = 1
+	}
+	aclChecksum, err := valkey.ACLChecksum(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	authentication := kubernetes.UntypedArgs{
+		"passwordFromSecret": kubernetes.UntypedArgs{
+			"name": pulumi.String(authSecretName(name)),
+			"key":  pulumi.String(authPasswordKey),
+		},
+	}
+	if spec.TLS.AuthClients {
+		authentication["clientCaCertSecret"] = kubernetes.UntypedArgs{
+			"name": pulumi.String(valkey.TLSSecretName(name)),
+			"key":  pulumi.String("ca.crt"),
+		}
+	}
+
+	dragonfly := kubernetes.UntypedArgs{
+		"replicas": pulumi.Int(spec.Replicas),
+		"image":    pulumi.String(image),
+		// Dragonfly only reads the ACL file at startup, so the operator rolls the pods whenever the checksum changes.
+		"annotations": pulumi.StringMap{
+			valkey.ACLChecksumAnnotation: pulumi.String(aclChecksum),
+		},
+		"args": pulumi.StringArray{
+			pulumi.String(fmt.Sprintf("--maxmemory=%d", maxMemory)),
+			pulumi.String(fmt.Sprintf("--cache_mode=%t", spec.Sizing.MaxMemoryPolicy != "noeviction")),
+			pulumi.String(fmt.Sprintf("--proactor_threads=%d", threads)),
+			pulumi.String(fmt.Sprintf("--masteruser=%s", replicaUsername)),
+		},
+		"env": pulumi.Array{
+			pulumi.Map{
+				"name": pulumi.String("DFLY_masterauth"),
+				"valueFrom": pulumi.Map{
+					"secretKeyRef": pulumi.Map{
+						"name": pulumi.String(authSecretName(name)),
+						"key":  pulumi.String(authReplicaPasswordKey),
+					},
+				},
+			},
+		},
+		"resources": pulumi.Map{
+			"requests": pulumi.Map{
+				"cpu":    pulumi.String(resources.CPURequest),
+				"memory": pulumi.String(resources.MemoryRequest),
+			},
+			"limits": pulumi.Map{
+				"cpu":    pulumi.String(resources.CPULimit),
+				"memory": pulumi.String(resources.MemoryLimit),
+			},
+		},
+		"nodeSelector":   valkey.NodeSelectorValues(spec),
+		"tolerations":    valkey.TolerationValues(spec),
+		"authentication": authentication,
+		"tlsSecretRef": kubernetes.UntypedArgs{
+			"name": pulumi.String(valkey.TLSSecretName(name)),
+		},
+		"aclFromSecret": kubernetes.UntypedArgs{
+			"name": pulumi.String(valkey.ACLSecretName(name)),
+			"key":  pulumi.String(valkey.ACLFileKey),
+		},
+	}
+	if spec.Persistence.VolumeEnabled() {
+		claim := kubernetes.UntypedArgs{
+			"accessModes": pulumi.StringArray{pulumi.String("ReadWriteOnce")},
+			"resources": pulumi.Map{
+				"requests": pulumi.Map{
+					"storage": pulumi.String(spec.Persistence.Size),
+				},
+			},
+		}
+		if spec.Persistence.StorageClass != "" {
+			claim["storageClassName"] = pulumi.String(spec.Persistence.StorageClass)
+		}
+		dragonfly["snapshot"] = kubernetes.UntypedArgs{
+			"cron":                      pulumi.String(snapshotSchedule),
+			"persistentVolumeClaimSpec": claim,
+		}
+	}
+
+	return &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("dragonflydb.io/v1alpha1"),
+		Kind:       pulumi.String("Dragonfly"),
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String(name),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    newDragonflyLabels(name),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": dragonfly,
+		},
+	}, nil
+}
+
+// proactorThreads returns the number of Dragonfly threads that fit a CPU limit such as `500m` or `2`, which is at
+// least one.
+func proactorThreads(cpuLimit string) (int, error) {
+	quantity, err := resource.ParseQuantity(cpuLimit)
+	if err != nil || quantity.Sign() <= 0 {
+		return 0, fmt.Errorf("%w: invalid cpu limit %q", ErrUnsupportedSetting, cpuLimit)
+	}
+	return max(1, int(quantity.MilliValue()/1000)), nil
+}
+
+// newDragonflyLabels returns the labels shared by the resources of a Dragonfly instance.
+func newDragonflyLabels(name string) pulumi.StringMap {
+	return pulumi.StringMap{
+		"app":                        pulumi.String("dragonfly"),
+		"app.kubernetes.io/instance": pulumi.String(name),
+	}
+}
+
+// authSecretName returns the name of the Secret holding the passwords the operator and replicas authenticate with.
+func authSecretName(name string) string {
+	return fmt.Sprintf("%s-auth", name)
+}
@@ -0,0 +1,76 @@
+package dragonfly
+
+import (
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	helmv4 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v4"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+const (
+	chartName      = "dragonfly-operator"
+	chartNamespace = "dragonfly-operator-system"
+	chartRepo      = "oci://ghcr.io/dragonflydb/dragonfly-operator/helm/dragonfly-operator"
+	chartVersion   = "v1.1.11"
+	configKind     = "dragonfly:kind"
+)
+
+// DeployDragonflyOperator deploys the Dragonfly operator Helm chart, which installs the `Dragonfly` CRD and reconciles
+// the instances deployed by [DeployDragonflyInstance].
+func DeployDragonflyOperator(
+	ctx *pulumi.Context,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	kind := config.GetBool(ctx, configKind)
+
+	ns, err := corev1.NewNamespace(
+		ctx,
+		chartNamespace,
+		&corev1.NamespaceArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name: pulumi.String(chartNamespace),
+				Labels: pulumi.StringMap{
+					"app": pulumi.String(chartName),
+				},
+			},
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	operator, err := helmv4.NewChart(
+		ctx,
+		chartName,
+		newDragonflyOperatorHelmChartArgs(ns, kind),
+		pulumi.Provider(provider),
+		pulumi.DependsOn(append(deps, ns)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{ns, operator}, nil
+}
+
+// newDragonflyOperatorHelmChartArgs returns the Helm chart values of the Dragonfly operator.
+//
+// [kind] runs a single operator replica, as a local cluster has no nodes to spread replicas over.
+func newDragonflyOperatorHelmChartArgs(ns *corev1.Namespace, kind bool) *helmv4.ChartArgs {
+	replicas := 2
+	if kind {
+		replicas = 1
+	}
+	return &helmv4.ChartArgs{
+		Chart:     pulumi.String(chartRepo),
+		Namespace: ns.Metadata.Name(),
+		Version:   pulumi.String(chartVersion),
+		Values: pulumi.Map{
+			"replicaCount": pulumi.Int(replicas),
+		},
+	}
+}
@@ -0,0 +1,286 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	// exportACLUsers holds the privileges of every user as of the last update, which the next preview diffs against.
+	exportACLUsers = "ACLUsers"
+	// exportACLChanges lists the privilege changes of the update.
+	exportACLChanges   = "ACLChanges"
+	keyPermissionRead  = "R"
+	keyPermissionWrite = "W"
+)
+
+// aclPrivileges are the command, key, and channel rules of a user, without its passwords.
+type aclPrivileges struct {
+	Commands []string `json:"commands"`
+	Keys     []string `json:"keys"`
+	Channels []string `json:"channels"`
+}
+
+// keyGrant is a key pattern and the permissions a user has on the keys it matches.
+type keyGrant struct {
+	pattern string
+	read    bool
+	write   bool
+}
+
+// newACLPrivileges sorts the ACL rules of a user into commands, keys, and channels. Password rules are dropped, so that
+// the privileges can be exported in plain text.
+func newACLPrivileges(rules []string) *aclPrivileges {
+	privileges := &aclPrivileges{Commands: []string{}, Keys: []string{}, Channels: []string{}}
+	for _, rule := range rules {
+		switch {
+		case strings.HasPrefix(rule, "+") || strings.HasPrefix(rule, "-"),
+			rule == "allcommands" || rule == "nocommands":
+			privileges.Commands = append(privileges.Commands, rule)
+		case strings.HasPrefix(rule, "~") || strings.HasPrefix(rule, "%"),
+			rule == "allkeys" || rule == "resetkeys":
+			privileges.Keys = append(privileges.Keys, rule)
+		case strings.HasPrefix(rule, "&"), rule == "allchannels" || rule == "resetchannels":
+			privileges.Channels = append(privileges.Channels, rule)
+		}
+	}
+	return privileges
+}
+
+// newACLUserPrivileges returns the privileges of every user of the instance keyed by username. They are read from the
+// rendered ACL file, so that the internal users of the instance, like the operator and metrics users, are diffed too.
+func newACLUserPrivileges(spec *InstanceSpec) (map[string]*aclPrivileges, error) {
+	aclContent, err := newValkeyUserACL(spec)
+	if err != nil {
+		return nil, err
+	}
+	users := map[string]*aclPrivileges{}
+	for _, line := range strings.Split(aclContent, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "user" {
+			continue
+		}
+		users[fields[1]] = newACLPrivileges(fields[2:])
+	}
+	return users, nil
+}
+
+// diffACLUsers describes how the users changed between two updates: users added or removed, command rules added,
+// removed, or reordered, key patterns widened or narrowed, and channel rules added or removed. The descriptions are
+// sorted by user.
+//
+// Command rules are compared as written. A category rule, like `+@write`, is described as granting every command of
+// the category, since this package doesn't know which commands a category holds.
+func diffACLUsers(previous map[string]*aclPrivileges, current map[string]*aclPrivileges) []string {
+	changes := []string{}
+	for _, username := range slices.Sorted(maps.Keys(current)) {
+		before, existed := previous[username]
+		after := current[username]
+		if !existed {
+			changes = append(changes, fmt.Sprintf(
+				"user %s added with commands [%s], keys [%s], and channels [%s]",
+				username,
+				strings.Join(after.Commands, " "),
+				strings.Join(after.Keys, " "),
+				strings.Join(after.Channels, " "),
+			))
+			continue
+		}
+		commandsChanged := false
+		for _, rule := range after.Commands {
+			if !slices.Contains(before.Commands, rule) {
+				commandsChanged = true
+				changes = append(changes, fmt.Sprintf("user %s: %s", username, describeCommandRule(rule, true)))
+			}
+		}
+		for _, rule := range before.Commands {
+			if !slices.Contains(after.Commands, rule) {
+				commandsChanged = true
+				changes = append(changes, fmt.Sprintf("user %s: %s", username, describeCommandRule(rule, false)))
+			}
+		}
+		if !commandsChanged && !slices.Equal(before.Commands, after.Commands) {
+			changes = append(changes, fmt.Sprintf(
+				"user %s: command rules reordered to [%s]",
+				username,
+				strings.Join(after.Commands, " "),
+			))
+		}
+		beforeKeys := parseKeyGrants(before.Keys)
+		afterKeys := parseKeyGrants(after.Keys)
+		for _, grant := range afterKeys {
+			if !grantsCover(beforeKeys, grant) {
+				changes = append(changes, fmt.Sprintf("user %s: key access widened to %s", username, grant))
+			}
+		}
+		for _, grant := range beforeKeys {
+			if !grantsCover(afterKeys, grant) {
+				changes = append(changes, fmt.Sprintf("user %s: key access narrowed from %s", username, grant))
+			}
+		}
+		for _, rule := range after.Channels {
+			if !slices.Contains(before.Channels, rule) {
+				changes = append(changes, fmt.Sprintf("user %s: %s", username, describeChannelRule(rule, true)))
+			}
+		}
+		for _, rule := range before.Channels {
+			if !slices.Contains(after.Channels, rule) {
+				changes = append(changes, fmt.Sprintf("user %s: %s", username, describeChannelRule(rule, false)))
+			}
+		}
+	}
+	for _, username := range slices.Sorted(maps.Keys(previous)) {
+		if _, ok := current[username]; !ok {
+			changes = append(changes, fmt.Sprintf("user %s removed", username))
+		}
+	}
+	return changes
+}
+
+// describeCommandRule describes a command rule that was added to or removed from a user. Category rules are flagged,
+// since they grant or revoke every command of the category at once.
+func describeCommandRule(rule string, added bool) string {
+	denies := strings.HasPrefix(rule, "-") || rule == "nocommands"
+	switch {
+	case rule == "allcommands" || rule == "nocommands":
+		rule = fmt.Sprintf("%s (every command)", rule)
+	case strings.HasPrefix(rule[1:], "@"):
+		rule = fmt.Sprintf("%s (every command of the %s category)", rule, rule[2:])
+	}
+	switch {
+	case added && !denies:
+		return fmt.Sprintf("granted %s", rule)
+	case added:
+		return fmt.Sprintf("revoked %s", rule)
+	case !denies:
+		return fmt.Sprintf("no longer granted %s", rule)
+	default:
+		return fmt.Sprintf("no longer revoked %s", rule)
+	}
+}
+
+// describeChannelRule describes a channel rule that was added to or removed from a user.
+func describeChannelRule(rule string, added bool) string {
+	switch {
+	case rule == "resetchannels" && added:
+		return "revoked the channels granted before resetchannels"
+	case rule == "resetchannels":
+		return "no longer resets its channels"
+	case added:
+		return fmt.Sprintf("granted channels %s", rule)
+	default:
+		return fmt.Sprintf("no longer granted channels %s", rule)
+	}
+}
+
+// parseKeyGrants parses `~pattern`, `%R~pattern`, `%W~pattern`, `%RW~pattern`, and `allkeys` rules. A `resetkeys`
+// rule drops the grants before it.
+func parseKeyGrants(rules []string) []*keyGrant {
+	var grants []*keyGrant
+	for _, rule := range rules {
+		switch {
+		case rule == "resetkeys":
+			grants = nil
+		case rule == "allkeys":
+			grants = append(grants, &keyGrant{pattern: "*", read: true, write: true})
+		case strings.HasPrefix(rule, "~"):
+			grants = append(grants, &keyGrant{pattern: rule[1:], read: true, write: true})
+		case strings.HasPrefix(rule, "%"):
+			permissions, pattern, ok := strings.Cut(rule[1:], "~")
+			if !ok {
+				continue
+			}
+			grants = append(grants, &keyGrant{
+				pattern: pattern,
+				read:    strings.Contains(strings.ToUpper(permissions), keyPermissionRead),
+				write:   strings.Contains(strings.ToUpper(permissions), keyPermissionWrite),
+			})
+		}
+	}
+	return grants
+}
+
+// String renders the grant as the ACL rule it was parsed from.
+func (g *keyGrant) String() string {
+	switch {
+	case g.read && g.write:
+		return "~" + g.pattern
+	case g.read:
+		return "%R~" + g.pattern
+	default:
+		return "%W~" + g.pattern
+	}
+}
+
+// covers reports whether every key the other grant allows, with its permissions, is allowed by this one. Patterns
+// are compared conservatively: a pattern only covers itself, `*`, or keys sharing the literal prefix of a pattern
+// ending in `*`.
+func (g *keyGrant) covers(other *keyGrant) bool {
+	if (other.read && !g.read) || (other.write && !g.write) {
+		return false
+	}
+	if g.pattern == other.pattern || g.pattern == "*" {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(g.pattern, "*")
+	return ok && !strings.ContainsAny(prefix, `*?[\`) && strings.HasPrefix(other.pattern, prefix)
+}
+
+// grantsCover reports whether any of the grants covers [grant].
+func grantsCover(grants []*keyGrant, grant *keyGrant) bool {
+	for _, candidate := range grants {
+		if candidate.covers(grant) {
+			return true
+		}
+	}
+	return false
+}
+
+// exportACLDiffOutputs exports the privileges of the instance's users, and logs and exports how they changed since
+// the last update. The previous privileges are read from this stack's own outputs, so the summary is available during
+// `pulumi preview`. The first update after the outputs are introduced only records the baseline.
+func exportACLDiffOutputs(ctx *pulumi.Context, name string, spec *InstanceSpec) error {
+	current, err := newACLUserPrivileges(spec)
+	if err != nil {
+		return err
+	}
+	value, err := toPulumiValue(current)
+	if err != nil {
+		return err
+	}
+	ctx.Export(exportName(name, exportACLUsers), value)
+
+	self, err := pulumi.NewStackReference(
+		ctx,
+		fmt.Sprintf("%s-acl-baseline", name),
+		&pulumi.StackReferenceArgs{
+			Name: pulumi.String(fmt.Sprintf("%s/%s/%s", ctx.Organization(), ctx.Project(), ctx.Stack())),
+		},
+	)
+	if err != nil {
+		return err
+	}
+	changes := self.GetOutput(pulumi.String(exportName(name, exportACLUsers))).ApplyT(
+		func(value interface{}) ([]string, error) {
+			if value == nil {
+				ctx.Log.Info(fmt.Sprintf("valkey instance %s: recording the ACL baseline", name), nil)
+				return []string{}, nil
+			}
+			previous := map[string]*aclPrivileges{}
+			err := fromPulumiValue(value, &previous)
+			if err != nil {
+				return nil, err
+			}
+			changes := diffACLUsers(previous, current)
+			for _, change := range changes {
+				ctx.Log.Info(fmt.Sprintf("valkey instance %s: %s", name, change), nil)
+			}
+			return changes, nil
+		},
+	).(pulumi.StringArrayOutput)
+	ctx.Export(exportName(name, exportACLChanges), changes)
+	return nil
+}
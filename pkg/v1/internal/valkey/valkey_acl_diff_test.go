@@ -0,0 +1,208 @@
+package valkey
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestKeyGrantCovers(t *testing.T) {
+	tests := []struct {
+		name  string
+		grant *keyGrant
+		other *keyGrant
+		want  bool
+	}{
+		{
+			name:  "same pattern",
+			grant: &keyGrant{pattern: "cache:*", read: true, write: true},
+			other: &keyGrant{pattern: "cache:*", read: true, write: true},
+			want:  true,
+		},
+		{
+			name:  "wildcard covers every pattern",
+			grant: &keyGrant{pattern: "*", read: true, write: true},
+			other: &keyGrant{pattern: "cache:[ab]?", read: true, write: true},
+			want:  true,
+		},
+		{
+			name:  "prefix covers longer patterns",
+			grant: &keyGrant{pattern: "cache:*", read: true, write: true},
+			other: &keyGrant{pattern: "cache:users:*", read: true, write: false},
+			want:  true,
+		},
+		{
+			name:  "prefix doesn't cover other prefixes",
+			grant: &keyGrant{pattern: "cache:*", read: true, write: true},
+			other: &keyGrant{pattern: "queue:*", read: true, write: true},
+			want:  false,
+		},
+		{
+			name:  "narrower pattern doesn't cover wider pattern",
+			grant: &keyGrant{pattern: "cache:users:*", read: true, write: true},
+			other: &keyGrant{pattern: "cache:*", read: true, write: true},
+			want:  false,
+		},
+		{
+			name:  "glob prefix is compared conservatively",
+			grant: &keyGrant{pattern: "cache:?*", read: true, write: true},
+			other: &keyGrant{pattern: "cache:?x", read: true, write: true},
+			want:  false,
+		},
+		{
+			name:  "read only doesn't cover write",
+			grant: &keyGrant{pattern: "*", read: true},
+			other: &keyGrant{pattern: "cache:*", write: true},
+			want:  false,
+		},
+		{
+			name:  "read write covers read only",
+			grant: &keyGrant{pattern: "cache:*", read: true, write: true},
+			other: &keyGrant{pattern: "cache:*", read: true},
+			want:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.grant.covers(test.other)
+			if got != test.want {
+				t.Errorf("%s covers %s = %t, want %t", test.grant, test.other, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyGrants(t *testing.T) {
+	grants := parseKeyGrants([]string{"~old:*", "resetkeys", "%R~cache:*", "%rw~queue:*", "%W~log:*", "allkeys"})
+	var got []string
+	for _, grant := range grants {
+		got = append(got, grant.String())
+	}
+	want := []string{"%R~cache:*", "~queue:*", "%W~log:*", "~*"}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseKeyGrants = %v, want %v", got, want)
+	}
+}
+
+func TestDiffACLUsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous map[string]*aclPrivileges
+		current  map[string]*aclPrivileges
+		want     []string
+	}{
+		{
+			name: "unchanged",
+			previous: map[string]*aclPrivileges{
+				"app": {Commands: []string{"+get"}, Keys: []string{"~app:*"}, Channels: []string{"&events"}},
+			},
+			current: map[string]*aclPrivileges{
+				"app": {Commands: []string{"+get"}, Keys: []string{"~app:*"}, Channels: []string{"&events"}},
+			},
+			want: []string{},
+		},
+		{
+			name:     "user added and removed",
+			previous: map[string]*aclPrivileges{"old": {Commands: []string{"+get"}}},
+			current: map[string]*aclPrivileges{
+				"new": {Commands: []string{"+get", "+set"}, Keys: []string{"~new:*"}, Channels: []string{"&news"}},
+			},
+			want: []string{
+				"user new added with commands [+get +set], keys [~new:*], and channels [&news]",
+				"user old removed",
+			},
+		},
+		{
+			name:     "commands granted and revoked",
+			previous: map[string]*aclPrivileges{"app": {Commands: []string{"+get", "-flushall"}}},
+			current:  map[string]*aclPrivileges{"app": {Commands: []string{"+set", "-keys"}}},
+			want: []string{
+				"user app: granted +set",
+				"user app: revoked -keys",
+				"user app: no longer granted +get",
+				"user app: no longer revoked -flushall",
+			},
+		},
+		{
+			name:     "category rules are flagged",
+			previous: map[string]*aclPrivileges{"app": {Commands: []string{"+get"}}},
+			current:  map[string]*aclPrivileges{"app": {Commands: []string{"+@read", "allcommands"}}},
+			want: []string{
+				"user app: granted +@read (every command of the read category)",
+				"user app: granted allcommands (every command)",
+				"user app: no longer granted +get",
+			},
+		},
+		{
+			name:     "reordered commands",
+			previous: map[string]*aclPrivileges{"app": {Commands: []string{"-@all", "+get"}}},
+			current:  map[string]*aclPrivileges{"app": {Commands: []string{"+get", "-@all"}}},
+			want:     []string{"user app: command rules reordered to [+get -@all]"},
+		},
+		{
+			name:     "keys widened",
+			previous: map[string]*aclPrivileges{"app": {Keys: []string{"%R~app:users:*"}}},
+			current:  map[string]*aclPrivileges{"app": {Keys: []string{"~app:*"}}},
+			want:     []string{"user app: key access widened to ~app:*"},
+		},
+		{
+			name:     "keys narrowed",
+			previous: map[string]*aclPrivileges{"app": {Keys: []string{"~app:*"}}},
+			current:  map[string]*aclPrivileges{"app": {Keys: []string{"%R~app:*"}}},
+			want:     []string{"user app: key access narrowed from ~app:*"},
+		},
+		{
+			name:     "channels changed",
+			previous: map[string]*aclPrivileges{"app": {Channels: []string{"&events"}}},
+			current:  map[string]*aclPrivileges{"app": {Channels: []string{"resetchannels", "allchannels"}}},
+			want: []string{
+				"user app: revoked the channels granted before resetchannels",
+				"user app: granted channels allchannels",
+				"user app: no longer granted channels &events",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := diffACLUsers(test.previous, test.current)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("diffACLUsers =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestNewACLUserPrivilegesIncludesInternalUsers(t *testing.T) {
+	spec := newDefaultInstanceSpec()
+	spec.DefaultUserCredentials = "default-password"
+	spec.SentinelUserCredentials = "sentinel-password"
+	spec.ReplicaUserCredentials = "replica-password"
+	spec.OperatorUserCredentials = "operator-password"
+	spec.Users = []*valkeyUser{
+		{Username: "app", Password: "app-password", EnabledCommands: []string{"+get", "~app:*", "&events"}},
+	}
+
+	users, err := newACLUserPrivileges(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []string{"default", "sentinel-user", "replica-user", operatorUsername, "app"} {
+		if _, ok := users[username]; !ok {
+			t.Errorf("user %s is missing", username)
+		}
+	}
+	app := users["app"]
+	if !slices.Equal(app.Commands, []string{"-@ALL", "+get"}) ||
+		!slices.Equal(app.Keys, []string{"~app:*"}) ||
+		!slices.Equal(app.Channels, []string{"&events"}) {
+		t.Errorf("app privileges = %+v", app)
+	}
+	for username, privileges := range users {
+		rules := slices.Concat(privileges.Commands, privileges.Keys, privileges.Channels)
+		for _, rule := range rules {
+			if strings.Contains(rule, "password") {
+				t.Errorf("user %s exports the password rule %s", username, rule)
+			}
+		}
+	}
+}
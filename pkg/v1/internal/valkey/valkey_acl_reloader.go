@@ -0,0 +1,104 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	aclReloaderContainerName = "acl-reloader"
+	aclReloaderInterval      = 10
+	tlsMountPath             = "/opt/bitnami/valkey/certs"
+)
+
+// aclReloaderScript polls the mounted ACL file and runs `ACL LOAD` whenever its checksum changes. The checksum is only
+// advanced after a successful load so that a transient failure is retried on the next tick.
+const aclReloaderScript = `#!/bin/bash
+acl_file="%[1]s"
+last_sum="$(sha256sum "${acl_file}" | cut -d ' ' -f 1)"
+while true; do
+  sleep %[2]d
+  current_sum="$(sha256sum "${acl_file}" | cut -d ' ' -f 1)"
+  if [ "${current_sum}" = "${last_sum}" ]; then
+    continue
+  fi
+  if valkey-cli --tls --cacert "%[3]s/ca.crt" --cert "%[3]s/tls.crt" --key "%[3]s/tls.key" \
+    -h 127.0.0.1 -p 6379 --user "%[4]s" ACL LOAD; then
+    echo "loaded ACL file with checksum ${current_sum}"
+    last_sum="${current_sum}"
+  else
+    echo "failed to load ACL file with checksum ${current_sum}" >&2
+  fi
+done
+`
+
+// newValkeyACLVolumes returns the volume that exposes the ACL Secret to the Valkey pods.
+func newValkeyACLVolumes(name string) pulumi.Array {
+	return pulumi.Array{
+		pulumi.Map{
+			"name": pulumi.String(aclVolumeName),
+			"secret": pulumi.Map{
+				"secretName": pulumi.String(aclSecretName(name)),
+				"items": pulumi.Array{
+					pulumi.Map{
+						"key":  pulumi.String(aclFileKey),
+						"path": pulumi.String(aclFileKey),
+					},
+				},
+			},
+		},
+	}
+}
+
+// newValkeyACLVolumeMounts returns the read-only mount of the ACL volume. It deliberately avoids `subPath` since
+// `subPath` mounts never receive Secret updates.
+func newValkeyACLVolumeMounts() pulumi.Array {
+	return pulumi.Array{
+		pulumi.Map{
+			"name":      pulumi.String(aclVolumeName),
+			"mountPath": pulumi.String(aclMountPath),
+			"readOnly":  pulumi.Bool(true),
+		},
+	}
+}
+
+// newValkeyACLReloaderSidecar returns the container spec of the sidecar that applies ACL file changes with `ACL LOAD`,
+// making user changes a zero-restart operation.
+func newValkeyACLReloaderSidecar(name string) pulumi.Map {
+	script := fmt.Sprintf(aclReloaderScript, aclFilePath, aclReloaderInterval, tlsMountPath, operatorUsername)
+	return pulumi.Map{
+		"name":    pulumi.String(aclReloaderContainerName),
+		"image":   pulumi.String(fmt.Sprintf("%s@%s", imageRepository, imageDigest)),
+		"command": pulumi.StringArray{pulumi.String("/bin/bash"), pulumi.String("-c")},
+		"args":    pulumi.StringArray{pulumi.String(script)},
+		"env": pulumi.Array{
+			pulumi.Map{
+				"name": pulumi.String(valkeyCLIAuthEnv),
+				"valueFrom": pulumi.Map{
+					"secretKeyRef": pulumi.Map{
+						"name": pulumi.String(aclSecretName(name)),
+						"key":  pulumi.String(operatorSecretKey),
+					},
+				},
+			},
+		},
+		"resources": pulumi.Map{
+			"limits": pulumi.Map{
+				"cpu":    pulumi.String("50m"),
+				"memory": pulumi.String("64Mi"),
+			},
+			"requests": pulumi.Map{
+				"cpu":    pulumi.String("10m"),
+				"memory": pulumi.String("32Mi"),
+			},
+		},
+		"volumeMounts": append(
+			newValkeyACLVolumeMounts(),
+			pulumi.Map{
+				"name":      pulumi.String(tlsVolumeName),
+				"mountPath": pulumi.String(tlsMountPath),
+				"readOnly":  pulumi.Bool(true),
+			},
+		),
+	}
+}
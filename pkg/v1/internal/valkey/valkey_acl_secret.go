@@ -0,0 +1,80 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	aclFileKey        = "users.acl"
+	aclFilePath       = aclMountPath + "/" + aclFileKey
+	aclMountPath      = "/opt/bitnami/valkey/acl"
+	aclVolumeName     = "valkey-acl"
+	operatorSecretKey = "operator-password"
+)
+
+// deployValkeyClusterACLSecret deploys the Secret holding the rendered ACL file. The Secret is mounted into the Valkey
+// pods as a directory, not with `subPath`, so the kubelet propagates updates to running pods without a restart.
+func deployValkeyClusterACLSecret(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	aclContent string,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (*corev1.Secret, error) {
+	args := newValkeyClusterACLSecretArgs(name, namespace, spec, aclContent)
+	secret, err := corev1.NewSecret(
+		ctx,
+		aclSecretName(name),
+		args,
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// newValkeyClusterACLSecretArgs returns the corev1.SecretArgs for the ACL Secret. Besides the ACL file, the Secret
+// carries the operator password used by the ACL reloader sidecar to run `ACL LOAD`, and the passwords of the metrics,
+// backup, and benchmark users when those are enabled.
+func newValkeyClusterACLSecretArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	aclContent string,
+) *corev1.SecretArgs {
+	data := pulumi.StringMap{
+		aclFileKey:        pulumi.String(aclContent),
+		operatorSecretKey: pulumi.String(spec.OperatorUserCredentials),
+	}
+	if spec.Metrics.Enabled {
+		data[metricsSecretKey] = pulumi.String(spec.Metrics.Password)
+	}
+	if spec.Backup.Enabled {
+		data[backupSecretKey] = pulumi.String(spec.Backup.Password)
+	}
+	if spec.Benchmark.Enabled {
+		data[benchmarkSecretKey] = pulumi.String(spec.Benchmark.Password)
+	}
+	return &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(aclSecretName(name)),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    newValkeyInstanceLabels(name),
+		},
+		Type:       pulumi.String("Opaque"),
+		StringData: pulumi.ToSecret(data).(pulumi.StringMapOutput),
+	}
+}
+
+// aclSecretName returns the name of an instance's ACL Secret.
+func aclSecretName(name string) string {
+	return fmt.Sprintf("%s-acl", name)
+}
@@ -0,0 +1,323 @@
+package valkey
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"slices"
+	"strings"
+)
+
+const (
+	aclSmokeComponent   = "acl-smoke"
+	aclSmokeMountPath   = "/smoke"
+	aclSmokeVolume      = "smoke"
+	aclSmokeArgument    = "smoke-test"
+	aclSmokeHashLength  = 10
+	aclSmokeJobDeadline = 600
+	// aclSmokeRetrySeconds is how long failing checks are retried for. The ACL Secret reaches the Valkey pods with the
+	// kubelet's Secret sync, and the reloader sidecar only then loads it on its next poll.
+	aclSmokeRetrySeconds = 300
+)
+
+// aclSmokeDeniedProbes are commands no user should be able to run unless they are granted explicitly.
+var aclSmokeDeniedProbes = []string{"flushall", "config|set", "debug", "shutdown", "acl|setuser"}
+
+// aclSmokeScript defines the checks run by the smoke test Job. `check_auth` pings the instance as a user and expects
+// the exact reply the user's grants allow. `check_allowed` and `check_denied` run a command as the user against a
+// replica and look for a NOPERM reply. Commands are padded with placeholder arguments until their arity is met, which
+// Valkey checks before the ACLs, and container commands like `CONFIG` are run through their HELP subcommand.
+//
+// Running on a replica keeps writes from being executed, since the replica rejects them as READONLY after the ACL
+// check, and the placeholder arguments make most other commands fail their syntax check. Without a replica only the
+// authentication is checked. The checks are retried until they pass or [aclSmokeRetrySeconds] run out, because the
+// Job may start before the new ACL file is loaded.
+const aclSmokeScript = `host="%[1]s"
+port=%[2]d
+target=""
+for node in %[3]s; do
+  if cli -h "${node}" -p "${port}" INFO replication | grep -q '^role:slave'; then
+    target="${node}"
+    break
+  fi
+done
+if [ -z "${target}" ]; then
+  echo "no replica to run commands against, only authentication is checked"
+fi
+fail() {
+  echo "FAIL: $*"
+  failures=$((failures + 1))
+}
+as_user() {
+  local user="$1" key="$2" server="$3"
+  shift 3
+  VALKEYCLI_AUTH="$(cat "%[4]s/${key}")" timeout 5 valkey-cli --tls --cacert "%[5]s/ca.crt" \
+    --cert "%[5]s/tls.crt" --key "%[5]s/tls.key" --user "${user}" -h "${server}" -p "${port}" "$@" 2>&1 || true
+}
+check_auth() {
+  out="$(as_user "$1" "$2" "${host}" PING)"
+  if [ "$3" = "PONG" ] && [ "${out}" = "PONG" ]; then
+    echo "ok: $1 authenticates with password $2"
+  elif [ "$3" = "NOPERM" ] && grep -q 'permissions to run' <<<"${out}"; then
+    echo "ok: $1 authenticates with password $2 and can't run PING"
+  else
+    fail "$1 can't authenticate with password $2: ${out}"
+  fi
+}
+run() {
+  read -r -a words <<<"${3//|/ }"
+  for count in 1 2 3 4 5 6 0; do
+    args=()
+    for ((i = 0; i < count; i++)); do
+      args+=("%[6]s")
+    done
+    out="$(as_user "$1" "$2" "${target}" "${words[@]}" "${args[@]}")"
+    if [ "${#words[@]}" -eq 1 ] && grep -q 'unknown subcommand' <<<"${out}"; then
+      words+=(help)
+      out="$(as_user "$1" "$2" "${target}" "${words[@]}")"
+    fi
+    if ! grep -q 'wrong number of arguments' <<<"${out}"; then
+      break
+    fi
+  done
+  echo "${out}"
+}
+check_allowed() {
+  if [ -z "${target}" ]; then
+    return 0
+  fi
+  out="$(run "$@")"
+  if grep -q -e 'permissions to run' -e 'unknown command' -e 'wrong number of arguments' <<<"${out}"; then
+    fail "$1 can't run $3: ${out}"
+  else
+    echo "ok: $1 can run $3"
+  fi
+}
+check_denied() {
+  if [ -z "${target}" ]; then
+    return 0
+  fi
+  out="$(run "$@")"
+  if grep -q 'permissions to run' <<<"${out}"; then
+    echo "ok: $1 can't run $3"
+  else
+    fail "$1 can run $3: ${out}"
+  fi
+}
+checks() {
+  failures=0
+%[7]s
+  [ "${failures}" -eq 0 ]
+}
+deadline=$((SECONDS + %[8]d))
+until checks; do
+  if [ "${SECONDS}" -ge "${deadline}" ]; then
+    echo "${failures} ACL checks failed" >&2
+    exit 1
+  fi
+  echo "${failures} ACL checks failed, retrying in case the ACL file isn't loaded yet"
+  sleep 10
+done
+`
+
+// aclSmokeChecks splits the grants of a user into the commands it should be allowed and denied to run. Categories are
+// not expanded, so users granted a category are only checked against their explicit grants and denials.
+func (u *valkeyUser) aclSmokeChecks() (allowed []string, denied []string) {
+	categories := false
+	for _, rule := range u.ACLRules() {
+		rule = strings.ToLower(rule)
+		switch {
+		case rule == "allcommands" || strings.HasPrefix(rule, "+@"):
+			categories = true
+		case rule == "+auth":
+			// AUTH is always allowed, and running it with a placeholder password would count as a failed login.
+		case strings.HasPrefix(rule, "+"):
+			allowed = append(allowed, strings.TrimPrefix(rule, "+"))
+		case strings.HasPrefix(rule, "-") && !strings.HasPrefix(rule, "-@"):
+			denied = append(denied, strings.TrimPrefix(rule, "-"))
+		}
+	}
+	if categories {
+		return allowed, denied
+	}
+	for _, probe := range aclSmokeDeniedProbes {
+		container, _, _ := strings.Cut(probe, "|")
+		if !slices.Contains(allowed, probe) && !slices.Contains(allowed, container) && !slices.Contains(denied, probe) {
+			denied = append(denied, probe)
+		}
+	}
+	return allowed, denied
+}
+
+// aclSmokePingReply returns the reply the user gets to PING, following the user's rules in order like Valkey does.
+// PING is in the `connection` and `fast` categories.
+func (u *valkeyUser) aclSmokePingReply() string {
+	reply := "NOPERM"
+	for _, rule := range u.ACLRules() {
+		switch strings.ToLower(rule) {
+		case "+ping", "+@connection", "+@fast", "+@all", "allcommands":
+			reply = "PONG"
+		case "-ping", "-@connection", "-@fast", "-@all", "nocommands":
+			reply = "NOPERM"
+		}
+	}
+	return reply
+}
+
+// newValkeyACLSmokeChecks returns the check invocations of [aclSmokeScript] for every user of the instance. Commands
+// are run with the user's first active password.
+func newValkeyACLSmokeChecks(spec *InstanceSpec) string {
+	var checks []string
+	for _, user := range spec.Users {
+		for i := range user.ActivePasswords() {
+			checks = append(checks, fmt.Sprintf(
+				"  check_auth %q %q %q",
+				user.Username,
+				aclSmokePasswordKey(user, i),
+				user.aclSmokePingReply(),
+			))
+		}
+		key := aclSmokePasswordKey(user, 0)
+		allowed, denied := user.aclSmokeChecks()
+		for _, cmd := range allowed {
+			checks = append(checks, fmt.Sprintf("  check_allowed %q %q %q", user.Username, key, cmd))
+		}
+		for _, cmd := range denied {
+			checks = append(checks, fmt.Sprintf("  check_denied %q %q %q", user.Username, key, cmd))
+		}
+	}
+	if len(checks) == 0 {
+		checks = append(checks, "  :")
+	}
+	return strings.Join(checks, "\n")
+}
+
+// deployValkeyACLSmokeTest runs a Job checking that every user of the instance can authenticate and is allowed and
+// denied the commands its grants say. The Job is named after a hash of the ACL file, so that it runs again whenever the
+// ACLs change. Pulumi waits for the Job to complete, which makes a failing check fail the update.
+func deployValkeyACLSmokeTest(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	aclContent string,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	secretName := fmt.Sprintf("%s-%s", name, aclSmokeComponent)
+	passwords := pulumi.StringMap{}
+	for _, user := range spec.Users {
+		for i, password := range user.ActivePasswords() {
+			passwords[aclSmokePasswordKey(user, i)] = pulumi.String(password)
+		}
+	}
+	secret, err := corev1.NewSecret(
+		ctx,
+		secretName,
+		&corev1.SecretArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(secretName),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newValkeyToolingLabels(name, aclSmokeComponent),
+			},
+			Type:       pulumi.String("Opaque"),
+			StringData: pulumi.ToSecret(passwords).(pulumi.StringMapOutput),
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := batchv1.NewJob(
+		ctx,
+		secretName,
+		newValkeyACLSmokeTestJobArgs(name, namespace, spec, aclContent, secretName),
+		pulumi.Provider(provider),
+		pulumi.DependsOn(append(deps, secret)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{secret, job}, nil
+}
+
+// newValkeyACLSmokeTestJobArgs returns the Job running [aclSmokeScript]. The operator user only looks up the replica,
+// and every check runs as the user it checks.
+func newValkeyACLSmokeTestJobArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	aclContent string,
+	secretName string,
+) *batchv1.JobArgs {
+	host := fmt.Sprintf("%s.%s.svc.cluster.local", name, spec.namespaceName(name))
+	script := newValkeyToolingScript(operatorUsername, fmt.Sprintf(
+		aclSmokeScript,
+		host,
+		valkeyPort,
+		strings.Join(nodeHostnames(name, spec), " "),
+		aclSmokeMountPath,
+		toolingTLSPath,
+		aclSmokeArgument,
+		newValkeyACLSmokeChecks(spec),
+		aclSmokeRetrySeconds,
+	))
+	labels := newValkeyToolingLabels(name, aclSmokeComponent)
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(aclContent)))[:aclSmokeHashLength]
+
+	return &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(revisionedJobName(secretName, hash)),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    labels,
+		},
+		Spec: &batchv1.JobSpecArgs{
+			ActiveDeadlineSeconds: pulumi.Int(aclSmokeJobDeadline),
+			BackoffLimit:          pulumi.Int(0),
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: labels,
+				},
+				Spec: &corev1.PodSpecArgs{
+					RestartPolicy:   pulumi.String("Never"),
+					SecurityContext: newValkeyToolingPodSecurityContext(),
+					Containers: corev1.ContainerArray{
+						newValkeyToolingContainer(
+							name,
+							aclSmokeComponent,
+							script,
+							corev1.EnvVarArray{
+								newSecretKeyEnvVar(valkeyCLIAuthEnv, aclSecretName(name), operatorSecretKey),
+							},
+							&corev1.VolumeMountArgs{
+								Name:      pulumi.String(aclSmokeVolume),
+								MountPath: pulumi.String(aclSmokeMountPath),
+								ReadOnly:  pulumi.Bool(true),
+							},
+						),
+					},
+					Volumes: append(
+						newValkeyToolingVolumes(name),
+						&corev1.VolumeArgs{
+							Name: pulumi.String(aclSmokeVolume),
+							Secret: &corev1.SecretVolumeSourceArgs{
+								SecretName: pulumi.String(secretName),
+							},
+						},
+					),
+				},
+			},
+		},
+	}
+}
+
+// aclSmokePasswordKey returns the key of a user's active password in the smoke test Secret.
+func aclSmokePasswordKey(user *valkeyUser, index int) string {
+	return fmt.Sprintf("%s-%d", user.Username, index)
+}
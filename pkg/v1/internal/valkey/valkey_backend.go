@@ -0,0 +1,184 @@
+package valkey
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ErrUnknownBackend is returned when an instance selects a backend that doesn't exist, or is deployed with the
+// package of another backend.
+var ErrUnknownBackend = fmt.Errorf("unknown backend")
+
+// Backend selects the server an instance runs. Every backend shares the instance spec, the cert-manager certificate
+// flow, and the ACL/user model of this package.
+type Backend string
+
+const (
+	// BackendValkey deploys the instance with [DeployValkeyCluster].
+	BackendValkey Backend = "valkey"
+	// BackendDragonfly deploys the instance with the `dragonfly` package.
+	BackendDragonfly Backend = "dragonfly"
+)
+
+// ACLFileKey is the key of the rendered ACL file in the Secret deployed by [DeployInstanceACLSecret].
+const ACLFileKey = aclFileKey
+
+// ACLChecksumAnnotation records the checksum of the ACL file the pods of an instance were started with.
+const ACLChecksumAnnotation = "valkey.fjarm.io/acl-checksum"
+
+// validateBackend checks that the backend is known.
+func validateBackend(backend Backend) error {
+	switch backend {
+	case BackendValkey, BackendDragonfly:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownBackend, backend)
+	}
+}
+
+// Validate checks the instance spec before any resource is registered. Backends other than Valkey call it before
+// checking the settings they support.
+func (spec *InstanceSpec) Validate() error {
+	return spec.validate()
+}
+
+// NamespaceName returns the namespace the instance is deployed to.
+func (spec *InstanceSpec) NamespaceName(name string) string {
+	return spec.namespaceName(name)
+}
+
+// UsesBackend reports whether any of the instances runs on [backend].
+func UsesBackend(instances []*InstanceConfig, backend Backend) bool {
+	for _, instance := range instances {
+		if instance.Backend == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// DeployInstanceNamespace deploys the namespace of an instance.
+func DeployInstanceNamespace(
+	ctx *pulumi.Context,
+	name string,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (*corev1.Namespace, error) {
+	return deployValkeyClusterNamespace(ctx, name, spec.namespaceName(name), provider, deps)
+}
+
+// DeployInstanceCertificate deploys the cert-manager server certificate of an instance to [TLSSecretName].
+func DeployInstanceCertificate(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (pulumi.Resource, error) {
+	return deployValkeyClusterCertificate(
+		ctx,
+		name,
+		namespace,
+		spec.namespaceName(name),
+		pulumi.StringArrayOutput{},
+		provider,
+		deps,
+	)
+}
+
+// DeployInstanceACLSecret renders the ACL file of an instance and deploys it under [ACLFileKey] to [ACLSecretName].
+func DeployInstanceACLSecret(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (*corev1.Secret, error) {
+	aclContent, err := newValkeyUserACL(spec)
+	if err != nil {
+		return nil, err
+	}
+	return deployValkeyClusterACLSecret(ctx, name, namespace, spec, aclContent, provider, deps)
+}
+
+// ACLChecksum returns the SHA-256 checksum of the ACL file of an instance. Backends that can't reload the ACL file
+// stamp their pods with it under [ACLChecksumAnnotation], so that every ACL change restarts them.
+func ACLChecksum(spec *InstanceSpec) (string, error) {
+	aclContent, err := newValkeyUserACL(spec)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(aclContent))), nil
+}
+
+// DeployInstanceConsumers publishes the credentials and client certificates of every user to its consumer namespaces.
+func DeployInstanceConsumers(
+	ctx *pulumi.Context,
+	name string,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	credentials, err := deployValkeyUserCredentialsSecrets(ctx, name, spec.Users, provider, deps)
+	if err != nil {
+		return nil, err
+	}
+	clientCerts, err := deployValkeyClientCertificates(ctx, name, spec, provider, deps)
+	if err != nil {
+		return nil, err
+	}
+	return append(credentials, clientCerts...), nil
+}
+
+// ExportInstanceOutputs exports the password rotation phases, the ACL change summary, and the [ConnectionInfo] of an
+// instance.
+func ExportInstanceOutputs(ctx *pulumi.Context, name string, spec *InstanceSpec, info *ConnectionInfo) error {
+	exportPasswordRotationPhasesOutput(ctx, name, spec.Users)
+	err := exportACLDiffOutputs(ctx, name, spec)
+	if err != nil {
+		return err
+	}
+	value, err := toPulumiValue(info)
+	if err != nil {
+		return err
+	}
+	ctx.Export(exportName(name, exportConnectionInfo), value)
+	return nil
+}
+
+// NewConnectionInfo returns the [ConnectionInfo] of a [ModeReplication] instance reached through its client Service
+// rather than sentinel.
+func NewConnectionInfo(name string, spec *InstanceSpec) *ConnectionInfo {
+	info := newConnectionInfo(name, spec)
+	info.HeadlessService = ""
+	info.Sentinel = ""
+	info.SentinelPort = 0
+	info.MasterSet = ""
+	return info
+}
+
+// NodeSelectorValues returns the node selector pinning the pods of an instance to its node pool.
+func NodeSelectorValues(spec *InstanceSpec) pulumi.StringMap {
+	return newValkeyNodeSelectorValues(spec)
+}
+
+// TolerationValues returns the tolerations letting the pods of an instance onto the tainted nodes of its node pool.
+func TolerationValues(spec *InstanceSpec) pulumi.Array {
+	return newValkeyTolerationValues(spec)
+}
+
+// TLSSecretName returns the name of the Secret holding the server certificate of an instance.
+func TLSSecretName(name string) string {
+	return clusterCertificateSecretName(name)
+}
+
+// ACLSecretName returns the name of the Secret holding the ACL file of an instance.
+func ACLSecretName(name string) string {
+	return aclSecretName(name)
+}
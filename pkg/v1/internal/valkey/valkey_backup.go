@@ -0,0 +1,382 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidBackupConfig is returned when backups or restores are configured inconsistently.
+var ErrInvalidBackupConfig = fmt.Errorf("invalid backup config")
+
+const (
+	backupComponent     = "backup"
+	backupMCAlias       = "backup"
+	backupMCHostKey     = "mc-host"
+	backupSecretKey     = "backup-password"
+	backupUsername      = "backup-user"
+	mcConfigDir         = toolingTmpPath + "/.mc"
+	mcImage             = "quay.io/minio/mc:RELEASE.2025-05-21T01-59-54Z"
+	restoreComponent    = "restore"
+	shardedDataPath     = "/bitnami/valkey/data"
+	replicationDataPath = "/data"
+	valkeyDataVolume    = "valkey-data"
+)
+
+// backupScript finds a replica and streams an RDB of it to the bucket over the replication protocol, then prunes
+// snapshots older than the retention. `--rdb -` makes the replica fork a fresh snapshot for the transfer, so the script
+// doesn't trigger a BGSAVE of its own.
+const backupScript = `replica=""
+for host in %[1]s; do
+  if cli -h "${host}" -p %[2]d INFO replication | grep -q '^role:slave'; then
+    replica="${host}"
+    break
+  fi
+done
+if [ -z "${replica}" ]; then
+  echo "no replica available to back up from" >&2
+  exit 1
+fi
+
+prefix="%[3]s/%[4]s/%[5]s"
+object="${prefix}/$(date -u +%%Y%%m%%dT%%H%%M%%SZ).rdb"
+%[6]s/mc mb --ignore-existing "%[3]s/%[4]s"
+cli -h "${replica}" -p %[2]d --rdb - | %[6]s/mc pipe "${object}"
+echo "uploaded ${object}"
+%[6]s/mc rm --recursive --force --older-than "%[7]dd" "${prefix}/"
+`
+
+// restoreScript seeds the empty data volume of the first pod with a snapshot before Valkey starts. The first pod is
+// the initial primary, and the other pods fully resync from it. A marker object written next to the snapshot makes the
+// restore one-shot, so volumes created later, e.g. by scaling up or replacing a PVC, never load the old snapshot.
+const restoreScript = `set -euo pipefail
+marker="%[2]s/%[3]s/%[4]s/.restored/%[5]s"
+if [ "${HOSTNAME##*-}" != "0" ]; then
+  echo "only the first pod restores, skipping restore"
+  exit 0
+fi
+if mc stat "${marker}" >/dev/null 2>&1; then
+  echo "%[5]s was already restored, skipping restore"
+  exit 0
+fi
+if [ -e "%[1]s/dump.rdb" ] || [ -e "%[1]s/appendonlydir" ]; then
+  echo "data directory is not empty, skipping restore"
+  exit 0
+fi
+mc cp "%[2]s/%[3]s/%[4]s/%[5]s" "%[1]s/dump.rdb"
+echo "restored by ${HOSTNAME}" | mc pipe "${marker}"
+echo "restored %[5]s"
+`
+
+// BackupConfig schedules RDB backups of an instance to an S3-compatible bucket. On Kind, LocalMinio deploys a MinIO
+// stand-in next to the instance and points Endpoint at it.
+type BackupConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Schedule string `json:"schedule"`
+	// Password of the backup ACL user.
+	Password      string `json:"password"`
+	Endpoint      string `json:"endpoint"`
+	Bucket        string `json:"bucket"`
+	Prefix        string `json:"prefix"`
+	AccessKey     string `json:"accessKey"`
+	SecretKey     string `json:"secretKey"`
+	RetentionDays int    `json:"retentionDays"`
+	LocalMinio    bool   `json:"localMinio"`
+}
+
+// RestoreConfig seeds a fresh instance from a snapshot taken by [BackupConfig]. The snapshot is read from the backup
+// bucket and prefix.
+type RestoreConfig struct {
+	// Snapshot is the object name relative to the backup prefix, e.g. `20250701T000000Z.rdb`. Leave empty to disable.
+	Snapshot string `json:"snapshot"`
+}
+
+// newDefaultBackupConfig returns the disabled backup config used when the stack config doesn't enable backups.
+func newDefaultBackupConfig() *BackupConfig {
+	return &BackupConfig{
+		Schedule:      "0 * * * *",
+		RetentionDays: 7,
+	}
+}
+
+// validateBackup checks that the bucket is reachable and that backups and restores are only used where they can work.
+func (spec *InstanceSpec) validateBackup() error {
+	backup := spec.Backup
+	restore := spec.Restore.Snapshot != ""
+	if !backup.Enabled && !restore {
+		return nil
+	}
+	if spec.Mode != ModeReplication {
+		return fmt.Errorf("%w: backups are only supported in %s mode", ErrInvalidBackupConfig, ModeReplication)
+	}
+	if backup.Bucket == "" || backup.AccessKey == "" || backup.SecretKey == "" {
+		return fmt.Errorf("%w: a bucket and its credentials are required", ErrInvalidBackupConfig)
+	}
+	if backup.Endpoint == "" && !backup.LocalMinio {
+		return fmt.Errorf("%w: an endpoint is required unless localMinio is set", ErrInvalidBackupConfig)
+	}
+	if _, err := url.Parse(backup.Endpoint); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBackupConfig, err)
+	}
+	if backup.Enabled && (backup.Password == "" || backup.Schedule == "" || backup.RetentionDays < 1) {
+		return fmt.Errorf("%w: backups require a password, schedule, and retention", ErrInvalidBackupConfig)
+	}
+	if backup.Enabled && spec.Replicas < 2 {
+		return fmt.Errorf("%w: backups are taken from a replica, at least 2 replicas are required", ErrInvalidBackupConfig)
+	}
+	if restore && !spec.Persistence.VolumeEnabled() {
+		return fmt.Errorf("%w: restoring requires a persistent volume", ErrInvalidBackupConfig)
+	}
+	return nil
+}
+
+// BackupUsername exposes [backupUsername] to the ACL template.
+func (b *BackupConfig) BackupUsername() string {
+	return backupUsername
+}
+
+// endpoint returns the S3 endpoint of the instance's bucket, which is the MinIO stand-in when LocalMinio is set.
+func (b *BackupConfig) endpoint(name string, spec *InstanceSpec) string {
+	if b.LocalMinio && b.Endpoint == "" {
+		return fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", minioServiceName(name), spec.namespaceName(name), minioPort)
+	}
+	return b.Endpoint
+}
+
+// prefix returns the object prefix snapshots are written under, defaulting to the instance name.
+func (b *BackupConfig) prefix(name string) string {
+	if b.Prefix == "" {
+		return name
+	}
+	return strings.Trim(b.Prefix, "/")
+}
+
+// mcHost returns the `MC_HOST_<alias>` URL embedding the bucket credentials.
+func (b *BackupConfig) mcHost(name string, spec *InstanceSpec) string {
+	endpoint, _ := url.Parse(b.endpoint(name, spec))
+	endpoint.User = url.UserPassword(b.AccessKey, b.SecretKey)
+	return endpoint.String()
+}
+
+// deployValkeyBackupSecret deploys the Secret holding the bucket credentials used by the backup CronJob, the restore
+// init container, and the MinIO stand-in.
+func deployValkeyBackupSecret(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (*corev1.Secret, error) {
+	secret, err := corev1.NewSecret(
+		ctx,
+		backupSecretName(name),
+		&corev1.SecretArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(backupSecretName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newValkeyInstanceLabels(name),
+			},
+			Type: pulumi.String("Opaque"),
+			StringData: pulumi.ToSecret(pulumi.StringMap{
+				backupMCHostKey:    pulumi.String(spec.Backup.mcHost(name, spec)),
+				minioRootUserKey:   pulumi.String(spec.Backup.AccessKey),
+				minioRootSecretKey: pulumi.String(spec.Backup.SecretKey),
+			}).(pulumi.StringMapOutput),
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// deployValkeyBackupCronJob deploys the CronJob that periodically backs up the instance.
+func deployValkeyBackupCronJob(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (*batchv1.CronJob, error) {
+	cronJob, err := batchv1.NewCronJob(
+		ctx,
+		fmt.Sprintf("%s-%s", name, backupComponent),
+		newValkeyBackupCronJobArgs(name, namespace, spec),
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return cronJob, nil
+}
+
+// newValkeyBackupCronJobArgs returns the CronJob running [backupScript]. The mc binary is copied out of the MinIO
+// client image by an init container so that the backup can stream straight from valkey-cli into the bucket.
+func newValkeyBackupCronJobArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+) *batchv1.CronJobArgs {
+	script := newValkeyToolingScript(backupUsername, fmt.Sprintf(
+		backupScript,
+		strings.Join(nodeHostnames(name, spec), " "),
+		valkeyPort,
+		backupMCAlias,
+		spec.Backup.Bucket,
+		spec.Backup.prefix(name),
+		toolingBinPath,
+		spec.Backup.RetentionDays,
+	))
+	labels := newValkeyToolingLabels(name, backupComponent)
+	toolsMount := &corev1.VolumeMountArgs{
+		Name:      pulumi.String(toolingBinVolume),
+		MountPath: pulumi.String(toolingBinPath),
+	}
+
+	return &batchv1.CronJobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(fmt.Sprintf("%s-%s", name, backupComponent)),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    labels,
+		},
+		Spec: &batchv1.CronJobSpecArgs{
+			Schedule:                   pulumi.String(spec.Backup.Schedule),
+			ConcurrencyPolicy:          pulumi.String("Forbid"),
+			SuccessfulJobsHistoryLimit: pulumi.Int(3),
+			FailedJobsHistoryLimit:     pulumi.Int(3),
+			JobTemplate: &batchv1.JobTemplateSpecArgs{
+				Spec: &batchv1.JobSpecArgs{
+					ActiveDeadlineSeconds: pulumi.Int(3600),
+					BackoffLimit:          pulumi.Int(1),
+					Template: &corev1.PodTemplateSpecArgs{
+						Metadata: &metav1.ObjectMetaArgs{
+							Labels: labels,
+						},
+						Spec: &corev1.PodSpecArgs{
+							RestartPolicy:   pulumi.String("Never"),
+							SecurityContext: newValkeyToolingPodSecurityContext(),
+							InitContainers: corev1.ContainerArray{
+								&corev1.ContainerArgs{
+									Name:  pulumi.String("mc"),
+									Image: pulumi.String(mcImage),
+									Command: pulumi.StringArray{
+										pulumi.String("cp"),
+										pulumi.String("/usr/bin/mc"),
+										pulumi.String(toolingBinPath + "/mc"),
+									},
+									VolumeMounts: corev1.VolumeMountArray{toolsMount},
+								},
+							},
+							Containers: corev1.ContainerArray{
+								newValkeyToolingContainer(
+									name,
+									backupComponent,
+									script,
+									corev1.EnvVarArray{
+										newSecretKeyEnvVar(valkeyCLIAuthEnv, aclSecretName(name), backupSecretKey),
+										newSecretKeyEnvVar(mcHostEnv(), backupSecretName(name), backupMCHostKey),
+										newEnvVar("MC_CONFIG_DIR", mcConfigDir),
+									},
+									toolsMount,
+								),
+							},
+							Volumes: append(
+								newValkeyToolingVolumes(name),
+								&corev1.VolumeArgs{
+									Name:     pulumi.String(toolingBinVolume),
+									EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+								},
+							),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// newValkeyRestoreInitContainer returns the init container added to the Valkey pods that runs [restoreScript] against
+// the data volume before the server starts.
+func newValkeyRestoreInitContainer(name string, spec *InstanceSpec) pulumi.Map {
+	dataPath := replicationDataPath
+	if spec.Mode == ModeCluster {
+		dataPath = shardedDataPath
+	}
+	script := fmt.Sprintf(
+		restoreScript,
+		dataPath,
+		backupMCAlias,
+		spec.Backup.Bucket,
+		spec.Backup.prefix(name),
+		spec.Restore.Snapshot,
+	)
+	return pulumi.Map{
+		"name":    pulumi.String(restoreComponent),
+		"image":   pulumi.String(mcImage),
+		"command": pulumi.StringArray{pulumi.String("/bin/bash"), pulumi.String("-c")},
+		"args":    pulumi.StringArray{pulumi.String(script)},
+		"env": pulumi.Array{
+			pulumi.Map{
+				"name": pulumi.String(mcHostEnv()),
+				"valueFrom": pulumi.Map{
+					"secretKeyRef": pulumi.Map{
+						"name": pulumi.String(backupSecretName(name)),
+						"key":  pulumi.String(backupMCHostKey),
+					},
+				},
+			},
+			pulumi.Map{
+				"name":  pulumi.String("MC_CONFIG_DIR"),
+				"value": pulumi.String(mcConfigDir),
+			},
+		},
+		"securityContext": pulumi.Map{
+			"runAsUser":    pulumi.Int(valkeyRunAsUser),
+			"runAsNonRoot": pulumi.Bool(true),
+		},
+		"volumeMounts": pulumi.Array{
+			pulumi.Map{
+				"name":      pulumi.String(valkeyDataVolume),
+				"mountPath": pulumi.String(dataPath),
+			},
+		},
+	}
+}
+
+// newValkeyInitContainers returns the init containers added to every Valkey pod of the instance.
+func newValkeyInitContainers(name string, spec *InstanceSpec) pulumi.Array {
+	initContainers := pulumi.Array{}
+	if spec.Restore.Snapshot != "" {
+		initContainers = append(initContainers, newValkeyRestoreInitContainer(name, spec))
+	}
+	if spec.labelsRoles() {
+		initContainers = append(initContainers, newValkeyKubectlInitContainer())
+	}
+	if len(spec.Modules) > 0 {
+		initContainers = append(initContainers, newValkeyModuleInitContainers(spec)...)
+	}
+	if spec.reloadsCertificates() {
+		initContainers = append(initContainers, newValkeyTLSChecksumInitContainer())
+	}
+	return initContainers
+}
+
+// mcHostEnv returns the environment variable the MinIO client reads the backup alias from.
+func mcHostEnv() string {
+	return fmt.Sprintf("MC_HOST_%s", backupMCAlias)
+}
+
+// backupSecretName returns the name of an instance's backup Secret.
+func backupSecretName(name string) string {
+	return fmt.Sprintf("%s-backup", name)
+}
@@ -0,0 +1,138 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	minioComponent     = "minio"
+	minioImage         = "quay.io/minio/minio:RELEASE.2025-06-13T11-33-47Z"
+	minioPort          = 9000
+	minioRootSecretKey = "minio-root-password"
+	minioRootUserKey   = "minio-root-user"
+)
+
+// deployValkeyBackupMinio deploys a single MinIO server next to the instance. It stands in for an S3-compatible bucket
+// on local clusters and keeps its data on an emptyDir, so it must not be used where backups have to survive the pod.
+func deployValkeyBackupMinio(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	labels := newValkeyMinioLabels(name)
+
+	deployment, err := appsv1.NewDeployment(
+		ctx,
+		minioServiceName(name),
+		&appsv1.DeploymentArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(minioServiceName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    labels,
+			},
+			Spec: &appsv1.DeploymentSpecArgs{
+				Replicas: pulumi.Int(1),
+				Selector: &metav1.LabelSelectorArgs{
+					MatchLabels: labels,
+				},
+				Template: &corev1.PodTemplateSpecArgs{
+					Metadata: &metav1.ObjectMetaArgs{
+						Labels: labels,
+					},
+					Spec: &corev1.PodSpecArgs{
+						Containers: corev1.ContainerArray{
+							&corev1.ContainerArgs{
+								Name:  pulumi.String(minioComponent),
+								Image: pulumi.String(minioImage),
+								Args: pulumi.StringArray{
+									pulumi.String("server"),
+									pulumi.String("/data"),
+								},
+								Env: corev1.EnvVarArray{
+									newSecretKeyEnvVar("MINIO_ROOT_USER", backupSecretName(name), minioRootUserKey),
+									newSecretKeyEnvVar("MINIO_ROOT_PASSWORD", backupSecretName(name), minioRootSecretKey),
+								},
+								Ports: corev1.ContainerPortArray{
+									&corev1.ContainerPortArgs{
+										Name:          pulumi.String("s3"),
+										ContainerPort: pulumi.Int(minioPort),
+									},
+								},
+								ReadinessProbe: &corev1.ProbeArgs{
+									HttpGet: &corev1.HTTPGetActionArgs{
+										Path: pulumi.String("/minio/health/ready"),
+										Port: pulumi.Int(minioPort),
+									},
+								},
+								VolumeMounts: corev1.VolumeMountArray{
+									&corev1.VolumeMountArgs{
+										Name:      pulumi.String("data"),
+										MountPath: pulumi.String("/data"),
+									},
+								},
+							},
+						},
+						Volumes: corev1.VolumeArray{
+							&corev1.VolumeArgs{
+								Name:     pulumi.String("data"),
+								EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+							},
+						},
+					},
+				},
+			},
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := corev1.NewService(
+		ctx,
+		minioServiceName(name),
+		&corev1.ServiceArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(minioServiceName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    labels,
+			},
+			Spec: &corev1.ServiceSpecArgs{
+				Selector: labels,
+				Ports: corev1.ServicePortArray{
+					&corev1.ServicePortArgs{
+						Name:       pulumi.String("s3"),
+						Port:       pulumi.Int(minioPort),
+						TargetPort: pulumi.String("s3"),
+					},
+				},
+			},
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{deployment, service}, nil
+}
+
+// newValkeyMinioLabels returns the labels of the MinIO stand-in.
+func newValkeyMinioLabels(name string) pulumi.StringMap {
+	labels := newValkeyInstanceLabels(name)
+	labels["app.kubernetes.io/component"] = pulumi.String(minioComponent)
+	return labels
+}
+
+// minioServiceName returns the name of the MinIO stand-in's Service.
+func minioServiceName(name string) string {
+	return fmt.Sprintf("%s-minio", name)
+}
@@ -0,0 +1,500 @@
+package valkey
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidBenchmarkConfig is returned when the benchmark is enabled with settings valkey-benchmark can't run.
+var ErrInvalidBenchmarkConfig = fmt.Errorf("invalid benchmark config")
+
+const (
+	benchmarkComponent = "benchmark"
+	// benchmarkKeyPrefix prefixes every key the benchmark writes, so that the benchmark user can't reach the keys of the
+	// applications and the benchmark keys can be deleted afterwards. valkey-benchmark replaces `{tag}` with a hash tag
+	// in [ModeCluster], which spreads the keys over the shards.
+	benchmarkKeyPrefix   = "valkey-benchmark:"
+	benchmarkHashLength  = 10
+	benchmarkJobDeadline = 1800
+	benchmarkPasswordEnv = "BENCHMARK_PASSWORD"
+	benchmarkResultsKey  = "results.csv"
+	benchmarkSecretKey   = "benchmark-password"
+	// benchmarkUsername is the ACL user valkey-benchmark authenticates as. It may only run the benchmarked commands on
+	// the keys starting with [benchmarkKeyPrefix].
+	benchmarkUsername = "benchmark-user"
+	exportBenchmark   = "Benchmark"
+	// maxBenchmarkDataSize caps the value size so that a benchmark can't exhaust the memory of the instance. The value
+	// is passed to valkey-benchmark as an argument, which Linux limits to 128KiB.
+	maxBenchmarkDataSize = 64 << 10
+)
+
+// benchmarkTest is a valkey-benchmark test run as a custom command, since the built-in tests write keys without a
+// common prefix.
+type benchmarkTest struct {
+	// command is the command the benchmark user is granted.
+	command string
+	// args follow the command. `%[1]s` is replaced with the key prefix, and `${value}` holds a value of the configured
+	// data size.
+	args string
+}
+
+// benchmarkTests lists the tests an instance can be benchmarked with. They mirror the built-in tests of
+// valkey-benchmark of the same name.
+var benchmarkTests = map[string]benchmarkTest{
+	"ping":    {command: "ping"},
+	"set":     {command: "set", args: `'%[1]skey:__rand_int__' "${value}"`},
+	"get":     {command: "get", args: `'%[1]skey:__rand_int__'`},
+	"incr":    {command: "incr", args: `'%[1]scounter:__rand_int__'`},
+	"lpush":   {command: "lpush", args: `'%[1]smylist' "${value}"`},
+	"rpush":   {command: "rpush", args: `'%[1]smylist' "${value}"`},
+	"lpop":    {command: "lpop", args: `'%[1]smylist'`},
+	"rpop":    {command: "rpop", args: `'%[1]smylist'`},
+	"sadd":    {command: "sadd", args: `'%[1]smyset' 'element:__rand_int__'`},
+	"spop":    {command: "spop", args: `'%[1]smyset'`},
+	"hset":    {command: "hset", args: `'%[1]smyhash' 'element:__rand_int__' "${value}"`},
+	"zadd":    {command: "zadd", args: `'%[1]smyzset' 0 'element:__rand_int__'`},
+	"zpopmin": {command: "zpopmin", args: `'%[1]smyzset'`},
+}
+
+// benchmarkScript runs every test of the benchmark against the primary in [ModeReplication], or the whole cluster in
+// [ModeCluster], and collects the CSV reports under the test names. The report is applied to the results ConfigMap
+// under its own field manager, so Pulumi keeps owning the rest of the ConfigMap and reads the report back once the Job
+// completes. The keys the tests wrote are deleted by [benchmarkCleanupScript] when the script exits.
+const benchmarkScript = `%[1]s
+echo "benchmarking ${host}"
+%[2]s
+value="$(head -c %[3]d /dev/zero | tr '\0' x)"
+results="%[4]s/%[5]s"
+: > "${results}"
+run() {
+  test="$1"
+  shift
+  valkey-benchmark --tls --cacert "%[6]s/ca.crt" --cert "%[6]s/tls.crt" --key "%[6]s/tls.key" \
+    -h "${host}" -p %[7]d --user "%[8]s" -a "${%[9]s}" %[10]s -n %[11]d -c %[12]d -P %[13]d -r %[14]d \
+    --csv "$@" > "%[4]s/run.csv"
+  if [ ! -s "${results}" ]; then
+    head -n 1 "%[4]s/run.csv" > "${results}"
+  fi
+  tail -n +2 "%[4]s/run.csv" | sed "s/^\"[^\"]*\"/\"${test}\"/" >> "${results}"
+}
+%[15]s
+cat "${results}"
+%[16]s/kubectl --namespace "%[17]s" create configmap "%[18]s" --from-file="%[5]s=${results}" \
+  --dry-run=client -o yaml | %[16]s/kubectl --namespace "%[17]s" apply --server-side --field-manager "%[19]s" -f -
+`
+
+// benchmarkCleanupScript deletes the keys starting with [benchmarkKeyPrefix] from every node valkey-benchmark wrote to.
+// SCAN is keyless, so in [ModeCluster] the UNLINKs of the keys a replica lists are redirected to its primary.
+const benchmarkCleanupScript = `cleanup() {
+  echo "deleting the benchmark keys"
+  for node in %[1]s; do
+    VALKEYCLI_AUTH="${%[2]s}" cli -h "${node}" -p %[3]d %[4]s --scan --pattern '%[5]s*' | sed 's/^/UNLINK /' |
+      VALKEYCLI_AUTH="${%[2]s}" cli -h "${node}" -p %[3]d %[4]s > /dev/null
+  done
+}
+trap cleanup EXIT`
+
+// BenchmarkConfig runs valkey-benchmark against an instance over TLS whenever the benchmark settings or the sizing of
+// the instance change. The results are exported as the `<name>Benchmark` stack output.
+type BenchmarkConfig struct {
+	Enabled bool `json:"enabled"`
+	// Password of the benchmark ACL user.
+	Password string `json:"password"`
+	Requests int    `json:"requests"`
+	Clients  int    `json:"clients"`
+	Pipeline int    `json:"pipeline"`
+	// DataSize is the size of the values in bytes, at most 64KiB.
+	DataSize int `json:"dataSize"`
+	// KeyspaceLen is the number of distinct keys the requests are spread over.
+	KeyspaceLen int      `json:"keyspaceLen"`
+	Tests       []string `json:"tests"`
+}
+
+// BenchmarkReport is exported as the `<name>Benchmark` stack output.
+type BenchmarkReport struct {
+	Requests int `json:"requests"`
+	Clients  int `json:"clients"`
+	Pipeline int `json:"pipeline"`
+	DataSize int `json:"dataSize"`
+	// Results are keyed by the upper-cased test names, e.g. `SET`.
+	Results map[string]*BenchmarkResult `json:"results"`
+}
+
+// BenchmarkResult is the throughput and latency distribution of a single valkey-benchmark test.
+type BenchmarkResult struct {
+	OpsPerSecond float64 `json:"opsPerSecond"`
+	AvgLatencyMs float64 `json:"avgLatencyMs"`
+	MinLatencyMs float64 `json:"minLatencyMs"`
+	P50LatencyMs float64 `json:"p50LatencyMs"`
+	P95LatencyMs float64 `json:"p95LatencyMs"`
+	P99LatencyMs float64 `json:"p99LatencyMs"`
+	MaxLatencyMs float64 `json:"maxLatencyMs"`
+}
+
+// newDefaultBenchmarkConfig returns the disabled benchmark config used when the stack config doesn't enable it.
+func newDefaultBenchmarkConfig() *BenchmarkConfig {
+	return &BenchmarkConfig{
+		Requests:    100000,
+		Clients:     50,
+		Pipeline:    1,
+		DataSize:    3,
+		KeyspaceLen: 100000,
+		Tests:       []string{"set", "get"},
+	}
+}
+
+// validate checks that an enabled benchmark has a password, positive parameters, and known tests.
+func (b *BenchmarkConfig) validate() error {
+	if !b.Enabled {
+		return nil
+	}
+	if b.Password == "" {
+		return fmt.Errorf("%w: the benchmark user requires a password", ErrInvalidBenchmarkConfig)
+	}
+	if b.Requests < 1 || b.Clients < 1 || b.Pipeline < 1 || b.DataSize < 1 || b.KeyspaceLen < 1 {
+		return fmt.Errorf(
+			"%w: requests, clients, pipeline, dataSize, and keyspaceLen must be positive",
+			ErrInvalidBenchmarkConfig,
+		)
+	}
+	if b.DataSize > maxBenchmarkDataSize {
+		return fmt.Errorf("%w: dataSize can't exceed %d bytes", ErrInvalidBenchmarkConfig, maxBenchmarkDataSize)
+	}
+	if len(b.Tests) == 0 {
+		return fmt.Errorf("%w: at least one test is required", ErrInvalidBenchmarkConfig)
+	}
+	for _, test := range b.Tests {
+		if _, ok := benchmarkTests[test]; !ok {
+			return fmt.Errorf("%w: unknown test %q", ErrInvalidBenchmarkConfig, test)
+		}
+	}
+	return nil
+}
+
+// BenchmarkUsername exposes [benchmarkUsername] to the ACL template.
+func (b *BenchmarkConfig) BenchmarkUsername() string {
+	return benchmarkUsername
+}
+
+// ACLRules returns the rules of the benchmark user: the commands of the selected tests on the keys starting with
+// [benchmarkKeyPrefix], the configuration and cluster topology commands valkey-benchmark starts with, and the commands
+// deleting the keys afterwards.
+func (b *BenchmarkConfig) ACLRules() []string {
+	rules := []string{"+ping", "+config|get", "+cluster|nodes", "+cluster|slots", "+scan", "+unlink"}
+	for _, test := range b.Tests {
+		command := "+" + benchmarkTests[test].command
+		if !slices.Contains(rules, command) {
+			rules = append(rules, command)
+		}
+	}
+	return append(rules, fmt.Sprintf("~%s*", benchmarkKeyPrefix))
+}
+
+// deployValkeyBenchmark deploys the Job running [benchmarkScript], the ConfigMap it saves its report to, and the
+// ServiceAccount it saves the report with, then reads the report back and exports it.
+func deployValkeyBenchmark(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	// The Job only applies the data of the ConfigMap, so Pulumi keeps track of it and deletes it with the instance.
+	resultsConfigMap, err := corev1.NewConfigMap(
+		ctx,
+		benchmarkResultsName(name),
+		&corev1.ConfigMapArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(benchmarkResultsName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newValkeyToolingLabels(name, benchmarkComponent),
+			},
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+		pulumi.IgnoreChanges([]string{"data"}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := rbacv1.PolicyRuleArray{
+		&rbacv1.PolicyRuleArgs{
+			ApiGroups:     pulumi.StringArray{pulumi.String("")},
+			Resources:     pulumi.StringArray{pulumi.String("configmaps")},
+			ResourceNames: pulumi.StringArray{pulumi.String(benchmarkResultsName(name))},
+			Verbs: pulumi.StringArray{
+				pulumi.String("get"),
+				pulumi.String("patch"),
+			},
+		},
+	}
+	rbac, err := deployValkeyToolingRBAC(ctx, name, benchmarkComponent, namespace, rules, provider, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := newValkeyBenchmarkJobArgs(name, namespace, spec)
+	if err != nil {
+		return nil, err
+	}
+	job, err := batchv1.NewJob(
+		ctx,
+		fmt.Sprintf("%s-%s", name, benchmarkComponent),
+		args,
+		pulumi.Provider(provider),
+		pulumi.DependsOn(append(append(deps, resultsConfigMap), rbac...)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ID only resolves once the Job has completed, so the report isn't read before it is rewritten, and a preview
+	// rerunning the benchmark doesn't read the report of the previous run.
+	id := job.Status.ApplyT(func(*batchv1.JobStatus) pulumi.ID {
+		return pulumi.ID(fmt.Sprintf("%s/%s", spec.namespaceName(name), benchmarkResultsName(name)))
+	}).(pulumi.IDOutput)
+	results, err := corev1.GetConfigMap(
+		ctx,
+		fmt.Sprintf("%s-report", benchmarkResultsName(name)),
+		id,
+		nil,
+		pulumi.Provider(provider),
+		pulumi.DependsOn([]pulumi.Resource{job}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Export(exportName(name, exportBenchmark), results.Data.ApplyT(
+		func(data map[string]string) (interface{}, error) {
+			report, err := newBenchmarkReport(spec.Benchmark, data[benchmarkResultsKey])
+			if err != nil {
+				return nil, err
+			}
+			return toPlainValue(report)
+		},
+	))
+	return append(append([]pulumi.Resource{resultsConfigMap}, rbac...), job), nil
+}
+
+// newValkeyBenchmarkJobArgs returns the Job running [benchmarkScript] as [benchmarkUsername].
+func newValkeyBenchmarkJobArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+) (*batchv1.JobArgs, error) {
+	revision, err := newValkeyBenchmarkRevision(spec)
+	if err != nil {
+		return nil, err
+	}
+	clusterFlag := ""
+	if spec.Mode == ModeCluster {
+		clusterFlag = "--cluster"
+	}
+	benchmark := spec.Benchmark
+	script := newValkeyToolingScript(benchmarkUsername, fmt.Sprintf(
+		benchmarkScript,
+		newBenchmarkTargetScript(name, spec),
+		newBenchmarkCleanupScript(name, spec),
+		benchmark.DataSize,
+		toolingTmpPath,
+		benchmarkResultsKey,
+		toolingTLSPath,
+		valkeyPort,
+		benchmarkUsername,
+		benchmarkPasswordEnv,
+		clusterFlag,
+		benchmark.Requests,
+		benchmark.Clients,
+		benchmark.Pipeline,
+		benchmark.KeyspaceLen,
+		newBenchmarkRunScript(benchmark),
+		toolingBinPath,
+		spec.namespaceName(name),
+		benchmarkResultsName(name),
+		benchmarkComponent,
+	))
+	labels := newValkeyToolingLabels(name, benchmarkComponent)
+	toolsMount := &corev1.VolumeMountArgs{
+		Name:      pulumi.String(toolingBinVolume),
+		MountPath: pulumi.String(toolingBinPath),
+	}
+
+	return &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(revisionedJobName(fmt.Sprintf("%s-%s", name, benchmarkComponent), revision)),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    labels,
+		},
+		Spec: &batchv1.JobSpecArgs{
+			ActiveDeadlineSeconds: pulumi.Int(benchmarkJobDeadline),
+			BackoffLimit:          pulumi.Int(0),
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: labels,
+				},
+				Spec: &corev1.PodSpecArgs{
+					ServiceAccountName: pulumi.String(toolingServiceAccountName(name, benchmarkComponent)),
+					RestartPolicy:      pulumi.String("Never"),
+					SecurityContext:    newValkeyToolingPodSecurityContext(),
+					InitContainers:     corev1.ContainerArray{newKubectlInitContainer(toolsMount)},
+					Containers: corev1.ContainerArray{
+						newValkeyToolingContainer(
+							name,
+							benchmarkComponent,
+							script,
+							corev1.EnvVarArray{
+								newSecretKeyEnvVar(benchmarkPasswordEnv, aclSecretName(name), benchmarkSecretKey),
+								newSecretKeyEnvVar(sentinelPasswordEnv, name, sentinelPasswordKey),
+							},
+							toolsMount,
+						),
+					},
+					Volumes: append(
+						newValkeyToolingVolumes(name),
+						&corev1.VolumeArgs{
+							Name:     pulumi.String(toolingBinVolume),
+							EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+						},
+					),
+				},
+			},
+		},
+	}, nil
+}
+
+// newBenchmarkTargetScript sets `host` to the node valkey-benchmark connects to: the primary reported by sentinel in
+// [ModeReplication], or the first seed node in [ModeCluster].
+func newBenchmarkTargetScript(name string, spec *InstanceSpec) string {
+	if spec.Mode == ModeCluster {
+		return fmt.Sprintf("host=%q", nodeHostname(name, spec, 0))
+	}
+	return fmt.Sprintf(
+		`host="$(VALKEYCLI_AUTH="${%[1]s}" valkey-cli --tls --cacert "%[2]s/ca.crt" --cert "%[2]s/tls.crt" \
+  --key "%[2]s/tls.key" -h "%[3]s" -p %[4]d SENTINEL get-master-addr-by-name "%[5]s" | head -n 1)"`,
+		sentinelPasswordEnv,
+		toolingTLSPath,
+		fmt.Sprintf("%s.%s.svc.cluster.local", name, spec.namespaceName(name)),
+		sentinelPort,
+		sentinelMasterSet,
+	)
+}
+
+// newBenchmarkRunScript returns the `run` calls of [benchmarkScript] running the selected tests in order.
+func newBenchmarkRunScript(benchmark *BenchmarkConfig) string {
+	var runs []string
+	for _, test := range benchmark.Tests {
+		definition := benchmarkTests[test]
+		run := fmt.Sprintf("run %s %s", strings.ToUpper(test), strings.ToUpper(definition.command))
+		if definition.args != "" {
+			run += " " + fmt.Sprintf(definition.args, benchmarkKeyPrefix+"{tag}:")
+		}
+		runs = append(runs, run)
+	}
+	return strings.Join(runs, "\n")
+}
+
+// newBenchmarkCleanupScript returns the [benchmarkCleanupScript] of the instance: the primary in [ModeReplication], or
+// every node in [ModeCluster].
+func newBenchmarkCleanupScript(name string, spec *InstanceSpec) string {
+	nodes := `"${host}"`
+	flags := ""
+	if spec.Mode == ModeCluster {
+		var hostnames []string
+		for _, hostname := range nodeHostnames(name, spec) {
+			hostnames = append(hostnames, fmt.Sprintf("%q", hostname))
+		}
+		nodes = strings.Join(hostnames, " ")
+		flags = "-c"
+	}
+	return fmt.Sprintf(
+		benchmarkCleanupScript,
+		nodes,
+		benchmarkPasswordEnv,
+		valkeyPort,
+		flags,
+		benchmarkKeyPrefix,
+	)
+}
+
+// newValkeyBenchmarkRevision returns a short hash of the benchmark settings, the sizing, and the image, so that the
+// benchmark reruns whenever one of them changes.
+func newValkeyBenchmarkRevision(spec *InstanceSpec) (string, error) {
+	encoded, err := json.Marshal([]interface{}{spec.Benchmark, spec.Sizing, spec.Mode, spec.Replicas, spec.Sharding})
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(append(encoded, []byte(imageDigest)...))
+	return fmt.Sprintf("%x", hash)[:benchmarkHashLength], nil
+}
+
+// newBenchmarkReport parses the CSV report of valkey-benchmark. Columns are looked up by their header, so that columns
+// added by newer versions are ignored.
+func newBenchmarkReport(benchmark *BenchmarkConfig, report string) (*BenchmarkReport, error) {
+	records, err := csv.NewReader(strings.NewReader(report)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: unreadable report: %w", ErrInvalidBenchmarkConfig, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%w: the report has no results", ErrInvalidBenchmarkConfig)
+	}
+	columns := map[string]int{}
+	for i, header := range records[0] {
+		columns[header] = i
+	}
+	fields := map[string]func(*BenchmarkResult) *float64{
+		"rps":            func(r *BenchmarkResult) *float64 { return &r.OpsPerSecond },
+		"avg_latency_ms": func(r *BenchmarkResult) *float64 { return &r.AvgLatencyMs },
+		"min_latency_ms": func(r *BenchmarkResult) *float64 { return &r.MinLatencyMs },
+		"p50_latency_ms": func(r *BenchmarkResult) *float64 { return &r.P50LatencyMs },
+		"p95_latency_ms": func(r *BenchmarkResult) *float64 { return &r.P95LatencyMs },
+		"p99_latency_ms": func(r *BenchmarkResult) *float64 { return &r.P99LatencyMs },
+		"max_latency_ms": func(r *BenchmarkResult) *float64 { return &r.MaxLatencyMs },
+	}
+	testColumn, ok := columns["test"]
+	if !ok {
+		return nil, fmt.Errorf("%w: the report has no test column", ErrInvalidBenchmarkConfig)
+	}
+
+	results := map[string]*BenchmarkResult{}
+	for _, record := range records[1:] {
+		result := &BenchmarkResult{}
+		for _, header := range slices.Sorted(maps.Keys(fields)) {
+			column, ok := columns[header]
+			if !ok || column >= len(record) {
+				continue
+			}
+			value, err := strconv.ParseFloat(record[column], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid %s %q", ErrInvalidBenchmarkConfig, header, record[column])
+			}
+			*fields[header](result) = value
+		}
+		results[record[testColumn]] = result
+	}
+	return &BenchmarkReport{
+		Requests: benchmark.Requests,
+		Clients:  benchmark.Clients,
+		Pipeline: benchmark.Pipeline,
+		DataSize: benchmark.DataSize,
+		Results:  results,
+	}, nil
+}
+
+// benchmarkResultsName returns the name of the ConfigMap the benchmark Job saves its report to.
+func benchmarkResultsName(name string) string {
+	return fmt.Sprintf("%s-%s-results", name, benchmarkComponent)
+}
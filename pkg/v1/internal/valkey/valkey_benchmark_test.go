@@ -0,0 +1,81 @@
+package valkey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewBenchmarkReport(t *testing.T) {
+	benchmark := newDefaultBenchmarkConfig()
+	report := `"test","rps","avg_latency_ms","min_latency_ms","p50_latency_ms","p95_latency_ms","p99_latency_ms",` +
+		`"max_latency_ms","new_column"
+"SET","81300.81","0.326","0.080","0.311","0.495","0.631","1.911","ignored"
+"GET","97087.38","0.273","0.072","0.263","0.399","0.503","1.239","ignored"
+`
+
+	got, err := newBenchmarkReport(benchmark, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Requests != benchmark.Requests || got.Clients != benchmark.Clients ||
+		got.Pipeline != benchmark.Pipeline || got.DataSize != benchmark.DataSize {
+		t.Errorf("report settings = %+v, want those of %+v", got, benchmark)
+	}
+	want := map[string]BenchmarkResult{
+		"SET": {
+			OpsPerSecond: 81300.81,
+			AvgLatencyMs: 0.326,
+			MinLatencyMs: 0.080,
+			P50LatencyMs: 0.311,
+			P95LatencyMs: 0.495,
+			P99LatencyMs: 0.631,
+			MaxLatencyMs: 1.911,
+		},
+		"GET": {
+			OpsPerSecond: 97087.38,
+			AvgLatencyMs: 0.273,
+			MinLatencyMs: 0.072,
+			P50LatencyMs: 0.263,
+			P95LatencyMs: 0.399,
+			P99LatencyMs: 0.503,
+			MaxLatencyMs: 1.239,
+		},
+	}
+	if len(got.Results) != len(want) {
+		t.Fatalf("results = %v, want %d tests", got.Results, len(want))
+	}
+	for test, result := range want {
+		if got.Results[test] == nil || *got.Results[test] != result {
+			t.Errorf("result of %s = %+v, want %+v", test, got.Results[test], result)
+		}
+	}
+}
+
+func TestNewBenchmarkReportMissingColumns(t *testing.T) {
+	got, err := newBenchmarkReport(newDefaultBenchmarkConfig(), "\"test\",\"rps\"\n\"PING_INLINE\",\"50000.00\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BenchmarkResult{OpsPerSecond: 50000}
+	if *got.Results["PING_INLINE"] != want {
+		t.Errorf("result = %+v, want %+v", got.Results["PING_INLINE"], want)
+	}
+}
+
+func TestNewBenchmarkReportErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "",
+		"header only":    "\"test\",\"rps\"\n",
+		"no test column": "\"name\",\"rps\"\n\"SET\",\"1\"\n",
+		"invalid number": "\"test\",\"rps\"\n\"SET\",\"fast\"\n",
+		"unreadable":     "\"test\",\"rps\"\n\"SET\n",
+	}
+	for name, report := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := newBenchmarkReport(newDefaultBenchmarkConfig(), report)
+			if !errors.Is(err, ErrInvalidBenchmarkConfig) {
+				t.Errorf("error = %v, want %v", err, ErrInvalidBenchmarkConfig)
+			}
+		})
+	}
+}
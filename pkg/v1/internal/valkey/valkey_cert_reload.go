@@ -0,0 +1,213 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ErrInvalidCertReloadConfig is returned when the certificate reload is enabled without a schedule.
+var ErrInvalidCertReloadConfig = fmt.Errorf("invalid certificate reload config")
+
+const (
+	certReloadComponent = "cert-reload"
+	// tlsChecksumContainerName is the init container recording the checksum of the certificate a Valkey pod mounted.
+	tlsChecksumContainerName = "tls-checksum"
+	tlsChecksumVolume        = "tls-checksum"
+	tlsChecksumMountPath     = "/opt/bitnami/valkey/tls-checksum"
+	tlsChecksumFile          = tlsChecksumMountPath + "/tls.crt.sha256"
+)
+
+// tlsChecksumScript records the checksum of the certificate a Valkey pod mounted before the Valkey container starts.
+// The mounted Secret is updated in place after a renewal, so the certificate file itself can't tell which certificate
+// Valkey loaded.
+const tlsChecksumScript = `sha256sum "%[1]s/tls.crt" | cut -d ' ' -f 1 > "%[2]s"`
+
+// certReloadScript restarts the Valkey pods once cert-manager renews the instance certificate. Valkey only reads its
+// certificate at startup, so every pod records the checksum of the certificate it mounted with [tlsChecksumScript] and
+// pods whose checksum is stale are restarted with [rollingRestartScript]. A pod without a recorded checksum is treated
+// as stale.
+const certReloadScript = `%[1]s
+current="$(kubectl get secret "%[2]s" -o jsonpath='{.data.tls\.crt}' | base64 -d | sha256sum | cut -d ' ' -f 1)"
+needs_restart() {
+  [ "$(kubectl exec "$1" -c valkey -- cat "%[3]s" 2>/dev/null)" != "${current}" ]
+}
+rolling_restart
+`
+
+// CertReloadConfig restarts the Valkey pods of a [ModeReplication] instance after its certificate is renewed. The
+// restart is sentinel-aware and fails the primary over last. It has no effect in [ModeCluster].
+type CertReloadConfig struct {
+	Enabled bool `json:"enabled"`
+	// Schedule is how often the certificate is checked for renewal.
+	Schedule string `json:"schedule"`
+}
+
+// newDefaultCertReloadConfig returns the certificate reload config used when the stack config doesn't override it.
+func newDefaultCertReloadConfig() *CertReloadConfig {
+	return &CertReloadConfig{
+		Enabled:  true,
+		Schedule: "*/15 * * * *",
+	}
+}
+
+// validate checks that an enabled certificate reload has a schedule.
+func (c *CertReloadConfig) validate() error {
+	if c.Enabled && c.Schedule == "" {
+		return fmt.Errorf("%w: a schedule is required", ErrInvalidCertReloadConfig)
+	}
+	return nil
+}
+
+// reloadsCertificates reports whether the Valkey pods of the instance are restarted after a certificate renewal.
+func (spec *InstanceSpec) reloadsCertificates() bool {
+	return spec.CertReload.Enabled && spec.Mode == ModeReplication
+}
+
+// deployValkeyCertReload deploys the CronJob that restarts the Valkey pods after a certificate renewal, along with the
+// ServiceAccount it uses to restart them.
+func deployValkeyCertReload(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	rules := append(
+		newRollingRestartPolicyRules(name),
+		&rbacv1.PolicyRuleArgs{
+			ApiGroups:     pulumi.StringArray{pulumi.String("")},
+			Resources:     pulumi.StringArray{pulumi.String("secrets")},
+			ResourceNames: pulumi.StringArray{pulumi.String(clusterCertificateSecretName(name))},
+			Verbs:         pulumi.StringArray{pulumi.String("get")},
+		},
+		&rbacv1.PolicyRuleArgs{
+			ApiGroups: pulumi.StringArray{pulumi.String("")},
+			Resources: pulumi.StringArray{pulumi.String("pods/exec")},
+			Verbs:     pulumi.StringArray{pulumi.String("create")},
+		},
+	)
+	rbac, err := deployValkeyToolingRBAC(
+		ctx,
+		name,
+		certReloadComponent,
+		namespace,
+		rules,
+		provider,
+		deps,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cronJob, err := batchv1.NewCronJob(
+		ctx,
+		fmt.Sprintf("%s-%s", name, certReloadComponent),
+		newValkeyCertReloadCronJobArgs(name, namespace, spec),
+		pulumi.Provider(provider),
+		pulumi.DependsOn(append(deps, rbac...)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return append(rbac, cronJob), nil
+}
+
+// newValkeyCertReloadCronJobArgs returns the CronJob running [certReloadScript].
+func newValkeyCertReloadCronJobArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+) *batchv1.CronJobArgs {
+	script := newValkeyToolingScript(operatorUsername, fmt.Sprintf(
+		certReloadScript,
+		newRollingRestartScript(name, spec),
+		clusterCertificateSecretName(name),
+		tlsChecksumFile,
+	))
+	labels := newValkeyToolingLabels(name, certReloadComponent)
+	toolsMount := &corev1.VolumeMountArgs{
+		Name:      pulumi.String(toolingBinVolume),
+		MountPath: pulumi.String(toolingBinPath),
+	}
+
+	return &batchv1.CronJobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(fmt.Sprintf("%s-%s", name, certReloadComponent)),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    labels,
+		},
+		Spec: &batchv1.CronJobSpecArgs{
+			Schedule:                   pulumi.String(spec.CertReload.Schedule),
+			ConcurrencyPolicy:          pulumi.String("Forbid"),
+			SuccessfulJobsHistoryLimit: pulumi.Int(1),
+			FailedJobsHistoryLimit:     pulumi.Int(3),
+			JobTemplate: &batchv1.JobTemplateSpecArgs{
+				Spec: &batchv1.JobSpecArgs{
+					ActiveDeadlineSeconds: pulumi.Int(3600),
+					BackoffLimit:          pulumi.Int(0),
+					Template: &corev1.PodTemplateSpecArgs{
+						Metadata: &metav1.ObjectMetaArgs{
+							Labels: labels,
+						},
+						Spec: &corev1.PodSpecArgs{
+							ServiceAccountName: pulumi.String(toolingServiceAccountName(name, certReloadComponent)),
+							RestartPolicy:      pulumi.String("Never"),
+							SecurityContext:    newValkeyToolingPodSecurityContext(),
+							InitContainers:     corev1.ContainerArray{newKubectlInitContainer(toolsMount)},
+							Containers: corev1.ContainerArray{
+								newValkeyToolingContainer(
+									name,
+									certReloadComponent,
+									script,
+									corev1.EnvVarArray{
+										newSecretKeyEnvVar(valkeyCLIAuthEnv, aclSecretName(name), operatorSecretKey),
+										newSecretKeyEnvVar(sentinelPasswordEnv, name, sentinelPasswordKey),
+									},
+									toolsMount,
+								),
+							},
+							Volumes: append(
+								newValkeyToolingVolumes(name),
+								&corev1.VolumeArgs{
+									Name:     pulumi.String(toolingBinVolume),
+									EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+								},
+							),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// newValkeyTLSChecksumInitContainer returns the chart values of the init container running [tlsChecksumScript].
+func newValkeyTLSChecksumInitContainer() pulumi.Map {
+	return pulumi.Map{
+		"name":    pulumi.String(tlsChecksumContainerName),
+		"image":   pulumi.String(fmt.Sprintf("%s@%s", imageRepository, imageDigest)),
+		"command": pulumi.StringArray{pulumi.String("/bin/bash"), pulumi.String("-c")},
+		"args":    pulumi.StringArray{pulumi.String(fmt.Sprintf(tlsChecksumScript, tlsMountPath, tlsChecksumFile))},
+		"securityContext": pulumi.Map{
+			"runAsUser":    pulumi.Int(valkeyRunAsUser),
+			"runAsNonRoot": pulumi.Bool(true),
+		},
+		"volumeMounts": pulumi.Array{
+			pulumi.Map{
+				"name":      pulumi.String("valkey-certificates"),
+				"mountPath": pulumi.String(tlsMountPath),
+				"readOnly":  pulumi.Bool(true),
+			},
+			pulumi.Map{
+				"name":      pulumi.String(tlsChecksumVolume),
+				"mountPath": pulumi.String(tlsChecksumMountPath),
+			},
+		},
+	}
+}
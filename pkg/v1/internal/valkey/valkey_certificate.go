@@ -0,0 +1,139 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/fjarm/infrastructure/pkg/v1/certmanager"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"net"
+	"slices"
+)
+
+// deployValkeyClusterCertificate deploys a cert-manager created/managed TLS certificate in the instance's namespace.
+func deployValkeyClusterCertificate(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	namespaceName string,
+	externalAddresses pulumi.StringArrayOutput,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (*apiextensions.CustomResource, error) {
+	certArgs, err := newValkeyClusterCertificateArgs(name, namespace, namespaceName, externalAddresses)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := apiextensions.NewCustomResource(
+		ctx,
+		clusterCertificateName(name),
+		certArgs,
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// newValkeyClusterCertificateArgs creates a new Certificate issued by cert-manager for a Valkey instance to use. The
+// DNS names cover the instance's Service, primary Service, and headless Service. When the instance is exposed,
+// [externalAddresses] are added as IP addresses or DNS names depending on their form.
+func newValkeyClusterCertificateArgs(
+	name string,
+	ns *corev1.Namespace,
+	namespaceName string,
+	externalAddresses pulumi.StringArrayOutput,
+) (*apiextensions.CustomResourceArgs, error) {
+	var dnsNames []string
+	for _, service := range []string{name, primaryServiceName(name), headlessServiceName(name)} {
+		dnsNames = append(
+			dnsNames,
+			fmt.Sprintf("*.%s.%s.svc.cluster.local", service, namespaceName),
+			fmt.Sprintf("%s.%s.svc.cluster.local", service, namespaceName),
+			fmt.Sprintf("%s.%s.svc", service, namespaceName),
+			fmt.Sprintf("%s.%s", service, namespaceName),
+			service,
+		)
+	}
+	dnsNames = append(dnsNames, "localhost")
+	ipAddresses := []string{"127.0.0.1"}
+
+	var dnsNamesInput pulumi.StringArrayInput = pulumi.ToStringArray(dnsNames)
+	var ipAddressesInput pulumi.StringArrayInput = pulumi.ToStringArray(ipAddresses)
+	if externalAddresses != (pulumi.StringArrayOutput{}) {
+		dnsNamesInput = externalAddresses.ApplyT(func(addresses []string) []string {
+			names := slices.Clone(dnsNames)
+			for _, address := range addresses {
+				if !isIPAddress(address) {
+					names = append(names, address)
+				}
+			}
+			return names
+		}).(pulumi.StringArrayOutput)
+		ipAddressesInput = externalAddresses.ApplyT(func(addresses []string) []string {
+			ips := slices.Clone(ipAddresses)
+			for _, address := range addresses {
+				if isIPAddress(address) {
+					ips = append(ips, address)
+				}
+			}
+			return ips
+		}).(pulumi.StringArrayOutput)
+	}
+
+	labels := newValkeyInstanceLabels(name)
+	labels["app.kubernetes.io/managed-by"] = pulumi.String("Helm")
+	labels["app.kubernetes.io/version"] = pulumi.String(chartVersion)
+	labels["helm.sh/chart"] = pulumi.String(fmt.Sprintf("%s-%s", chartName, chartVersion))
+
+	cra := apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("cert-manager.io/v1"),
+		Kind:       pulumi.String("Certificate"),
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String(clusterCertificateName(name)),
+			Namespace: ns.Metadata.Name(),
+			Labels:    labels,
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": kubernetes.UntypedArgs{
+				"commonName":  pulumi.String(name),
+				"dnsNames":    dnsNamesInput,
+				"duration":    pulumi.String("87600h0m0s"),
+				"ipAddresses": ipAddressesInput,
+				"issuerRef": kubernetes.UntypedArgs{
+					"kind":  pulumi.String("ClusterIssuer"),
+					"name":  pulumi.String(certmanager.InternalClusterIssuerName),
+					"group": pulumi.String("cert-manager.io"),
+				},
+				"secretName": pulumi.String(clusterCertificateSecretName(name)),
+				"usages": pulumi.StringArray{
+					pulumi.String("client auth"),
+					pulumi.String("server auth"),
+					pulumi.String("signing"),
+					pulumi.String("key encipherment"),
+				},
+			},
+		},
+	}
+	return &cra, nil
+}
+
+// isIPAddress reports whether an address is an IP address rather than a DNS name.
+func isIPAddress(address string) bool {
+	return net.ParseIP(address) != nil
+}
+
+// clusterCertificateName returns the name of an instance's Certificate.
+func clusterCertificateName(name string) string {
+	return fmt.Sprintf("%s-certificate", name)
+}
+
+// clusterCertificateSecretName returns the name of the Secret cert-manager writes an instance's certificate to.
+func clusterCertificateSecretName(name string) string {
+	return fmt.Sprintf("%s-tls-secret", name)
+}
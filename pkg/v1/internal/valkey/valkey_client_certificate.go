@@ -0,0 +1,149 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/fjarm/infrastructure/pkg/v1/certmanager"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ErrInvalidTLSConfig is returned when client certificates are mapped to users without being required, or when a
+// certificate of the instance would authenticate as one of its users.
+var ErrInvalidTLSConfig = fmt.Errorf("invalid tls config")
+
+// TLSConfig controls mutual TLS between clients and an instance.
+type TLSConfig struct {
+	// AuthClients makes the server reject connections that don't present a client certificate signed by the
+	// internal cluster issuer.
+	AuthClients bool `json:"authClients"`
+	// CertificateUsers authenticates a connection as the ACL user named by the client certificate's common name via
+	// `tls-auth-clients-user CN`. It requires a Valkey release that supports the directive, which the server rejects
+	// otherwise, so it stays off unless the image pinned by [imageDigest] is known to support it.
+	CertificateUsers bool `json:"certificateUsers"`
+	// ClientCertificateDuration is the lifetime of the per-consumer client certificates.
+	ClientCertificateDuration string `json:"clientCertificateDuration"`
+}
+
+// newDefaultTLSConfig returns the TLS config used when the stack config doesn't override it: client certificates are
+// required, and users still authenticate with their passwords.
+func newDefaultTLSConfig() *TLSConfig {
+	return &TLSConfig{
+		AuthClients:               true,
+		ClientCertificateDuration: "2160h0m0s",
+	}
+}
+
+// validate rejects mapping certificates to users when clients don't have to present a certificate.
+func (t *TLSConfig) validate() error {
+	if t.CertificateUsers && !t.AuthClients {
+		return fmt.Errorf("%w: certificateUsers requires authClients", ErrInvalidTLSConfig)
+	}
+	return nil
+}
+
+// certificateCommonNames returns the common names of the certificates the instance called [name] issues for itself.
+// The pods and tooling present them as client certificates, so with CertificateUsers they would log in as the ACL user
+// of the same name.
+func (spec *InstanceSpec) certificateCommonNames(name string) []string {
+	names := []string{name}
+	if spec.Proxy.Enabled {
+		names = append(names, proxyServiceName(name))
+	}
+	return names
+}
+
+// validateCertificateUsers rejects ACL users named like one of [commonNames] when certificates authenticate as users.
+func (spec *InstanceSpec) validateCertificateUsers(commonNames []string) error {
+	if !spec.TLS.CertificateUsers {
+		return nil
+	}
+	users, err := newACLUserPrivileges(spec)
+	if err != nil {
+		return err
+	}
+	for _, commonName := range commonNames {
+		if _, ok := users[commonName]; ok {
+			return fmt.Errorf(
+				"%w: certificates named %s would authenticate as the ACL user of the same name",
+				ErrInvalidTLSConfig,
+				commonName,
+			)
+		}
+	}
+	return nil
+}
+
+// deployValkeyClientCertificates issues a client certificate for every user into each of its consumer namespaces. The
+// certificate's common name is the ACL username so that the certificate maps to exactly one user.
+func deployValkeyClientCertificates(
+	ctx *pulumi.Context,
+	name string,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	var certs []pulumi.Resource
+	for _, user := range spec.Users {
+		for _, consumerNamespace := range user.ConsumerNamespaces {
+			cert, err := apiextensions.NewCustomResource(
+				ctx,
+				fmt.Sprintf("%s-%s", consumerNamespace, clientCertificateName(name, user)),
+				newValkeyClientCertificateArgs(name, spec, user, consumerNamespace),
+				pulumi.Provider(provider),
+				pulumi.DependsOn(deps),
+			)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+// newValkeyClientCertificateArgs returns the client Certificate of a user in one of its consumer namespaces.
+func newValkeyClientCertificateArgs(
+	name string,
+	spec *InstanceSpec,
+	user *valkeyUser,
+	consumerNamespace string,
+) *apiextensions.CustomResourceArgs {
+	return &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("cert-manager.io/v1"),
+		Kind:       pulumi.String("Certificate"),
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String(clientCertificateName(name, user)),
+			Namespace: pulumi.String(consumerNamespace),
+			Labels:    newValkeyInstanceLabels(name),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": kubernetes.UntypedArgs{
+				"commonName": pulumi.String(user.Username),
+				"duration":   pulumi.String(spec.TLS.ClientCertificateDuration),
+				"issuerRef": kubernetes.UntypedArgs{
+					"kind":  pulumi.String("ClusterIssuer"),
+					"name":  pulumi.String(certmanager.InternalClusterIssuerName),
+					"group": pulumi.String("cert-manager.io"),
+				},
+				"secretName": pulumi.String(clientCertificateSecretName(name, user)),
+				"usages": pulumi.StringArray{
+					pulumi.String("client auth"),
+					pulumi.String("digital signature"),
+					pulumi.String("key encipherment"),
+				},
+			},
+		},
+	}
+}
+
+// clientCertificateName returns the name of a user's client Certificate.
+func clientCertificateName(name string, user *valkeyUser) string {
+	return fmt.Sprintf("%s-%s-client-certificate", name, user.Username)
+}
+
+// clientCertificateSecretName returns the name of the Secret cert-manager writes a user's client certificate to.
+func clientCertificateSecretName(name string, user *valkeyUser) string {
+	return fmt.Sprintf("%s-%s-client-tls", name, user.Username)
+}
@@ -0,0 +1,56 @@
+package valkey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInstanceSpecValidateCertificateUsers(t *testing.T) {
+	tests := []struct {
+		name             string
+		certificateUsers bool
+		proxy            bool
+		username         string
+		want             error
+	}{
+		{
+			name:     "certificates don't authenticate as users",
+			username: "cache",
+		},
+		{
+			name:             "users named unlike the certificates",
+			certificateUsers: true,
+			username:         "app",
+		},
+		{
+			name:             "user named like the instance",
+			certificateUsers: true,
+			username:         "cache",
+			want:             ErrInvalidTLSConfig,
+		},
+		{
+			name:             "user named like the proxy",
+			certificateUsers: true,
+			proxy:            true,
+			username:         "cache-proxy",
+			want:             ErrInvalidTLSConfig,
+		},
+		{
+			name:             "user named like the proxy of a disabled proxy",
+			certificateUsers: true,
+			username:         "cache-proxy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := newTestInstanceSpec()
+			spec.TLS.CertificateUsers = tt.certificateUsers
+			spec.Proxy.Enabled = tt.proxy
+			spec.Users = []*valkeyUser{{Username: tt.username, Password: "user-password"}}
+			err := spec.validateCertificateUsers(spec.certificateCommonNames("cache"))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validateCertificateUsers() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
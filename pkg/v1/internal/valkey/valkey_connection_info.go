@@ -0,0 +1,154 @@
+package valkey
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	exportConnectionInfo = "Connection"
+	caBundleKey          = "ca.crt"
+	sentinelMasterSet    = "mymaster"
+	sentinelPort         = 26379
+	valkeyPort           = 6379
+)
+
+// ConnectionInfo is exported as the `<name>Connection` stack output and tells clients how to reach an instance. In
+// [ModeReplication] clients either connect to the Service of the current primary or discover the primary through
+// sentinel, while in [ModeCluster] they bootstrap from a list of seed nodes and follow MOVED redirects. Dependent
+// stacks read it with [LookupConnectionInfo].
+type ConnectionInfo struct {
+	Mode      Mode   `json:"mode"`
+	Namespace string `json:"namespace"`
+	// Service is the `host:port` of the client Service, and ServiceHost its DNS name. In [ModeReplication] the Service
+	// only selects the current primary and follows a failover within a few seconds; clients that can't tolerate that
+	// delay discover the primary through Sentinel and MasterSet instead. In [ModeCluster] it selects every node.
+	Service         string `json:"service"`
+	ServiceHost     string `json:"serviceHost"`
+	Port            int    `json:"port"`
+	HeadlessService string `json:"headlessService,omitempty"`
+	TLS             bool   `json:"tls"`
+	// TLSSecret is the Secret holding the instance's server certificate.
+	TLSSecret string    `json:"tlsSecret"`
+	CABundle  *CABundle `json:"caBundle"`
+	// Users lists the ACL usernames of the instance's consumers.
+	Users []string `json:"users"`
+	// Sentinel, SentinelPort, and MasterSet are only set in [ModeReplication].
+	Sentinel     string `json:"sentinel,omitempty"`
+	SentinelPort int    `json:"sentinelPort,omitempty"`
+	MasterSet    string `json:"masterSet,omitempty"`
+	// SeedNodes, Shards, and ReplicasPerShard are only set in [ModeCluster].
+	SeedNodes        []string `json:"seedNodes,omitempty"`
+	Shards           int      `json:"shards,omitempty"`
+	ReplicasPerShard int      `json:"replicasPerShard,omitempty"`
+	// Proxy is the `host:port` of the connection-pooling proxy, and ProxyUser the user clients log in to it as. Both are
+	// only set when the proxy is enabled.
+	Proxy     string `json:"proxy,omitempty"`
+	ProxyUser string `json:"proxyUser,omitempty"`
+}
+
+// CABundle references the CA certificate clients verify the instance's certificate with.
+type CABundle struct {
+	Namespace string `json:"namespace"`
+	Secret    string `json:"secret"`
+	Key       string `json:"key"`
+}
+
+// newConnectionInfo returns the connection info of an instance.
+func newConnectionInfo(name string, spec *InstanceSpec) *ConnectionInfo {
+	namespaceName := spec.namespaceName(name)
+	host := fmt.Sprintf("%s.%s.svc.cluster.local", name, namespaceName)
+	serviceHost := host
+	if spec.labelsRoles() {
+		serviceHost = fmt.Sprintf("%s.%s.svc.cluster.local", primaryServiceName(name), namespaceName)
+	}
+	info := &ConnectionInfo{
+		Mode:            spec.Mode,
+		Namespace:       namespaceName,
+		Service:         fmt.Sprintf("%s:%d", serviceHost, valkeyPort),
+		ServiceHost:     serviceHost,
+		Port:            valkeyPort,
+		HeadlessService: fmt.Sprintf("%s.%s.svc.cluster.local", headlessServiceName(name), namespaceName),
+		TLS:             true,
+		TLSSecret:       clusterCertificateSecretName(name),
+		CABundle: &CABundle{
+			Namespace: namespaceName,
+			Secret:    clusterCertificateSecretName(name),
+			Key:       caBundleKey,
+		},
+		Users: []string{},
+	}
+	for _, user := range spec.Users {
+		info.Users = append(info.Users, user.Username)
+	}
+	switch spec.Mode {
+	case ModeCluster:
+		for i := 0; i < spec.Sharding.Nodes(); i++ {
+			info.SeedNodes = append(info.SeedNodes, fmt.Sprintf("%s:%d", nodeHostname(name, spec, i), valkeyPort))
+		}
+		info.Shards = spec.Sharding.Shards
+		info.ReplicasPerShard = spec.Sharding.ReplicasPerShard
+	default:
+		info.Sentinel = fmt.Sprintf("%s:%d", host, sentinelPort)
+		info.SentinelPort = sentinelPort
+		info.MasterSet = sentinelMasterSet
+	}
+	if spec.Proxy.Enabled {
+		info.Proxy = fmt.Sprintf("%s.%s.svc.cluster.local:%d", proxyServiceName(name), namespaceName, valkeyPort)
+		info.ProxyUser = spec.Proxy.Username
+	}
+	return info
+}
+
+// LookupConnectionInfo reads the [ConnectionInfo] of the instance called [name] from the stack deploying it. The
+// output resolves to a *ConnectionInfo.
+func LookupConnectionInfo(ref *pulumi.StackReference, name string) pulumi.Output {
+	return ref.GetOutput(pulumi.String(exportName(name, exportConnectionInfo))).ApplyT(
+		func(value interface{}) (*ConnectionInfo, error) {
+			info := &ConnectionInfo{}
+			err := fromPulumiValue(value, info)
+			if err != nil {
+				return nil, err
+			}
+			return info, nil
+		},
+	)
+}
+
+// toPulumiValue converts a struct to the plain maps and lists a stack output holds, keyed by its JSON field names.
+func toPulumiValue(value interface{}) (pulumi.Output, error) {
+	decoded, err := toPlainValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return pulumi.ToOutput(decoded), nil
+}
+
+// toPlainValue converts a struct to plain maps and lists keyed by its JSON field names.
+func toPlainValue(value interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	err = json.Unmarshal(encoded, &decoded)
+	if err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// fromPulumiValue decodes a stack output exported with [toPulumiValue] into [out].
+func fromPulumiValue(value interface{}, out interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, out)
+}
+
+// headlessServiceName returns the name of the headless Service the charts create for an instance.
+func headlessServiceName(name string) string {
+	return fmt.Sprintf("%s-headless", name)
+}
@@ -0,0 +1,365 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"net"
+	"slices"
+)
+
+// ErrInvalidExposureConfig is returned when the external exposure of an instance can't be set up.
+var ErrInvalidExposureConfig = fmt.Errorf("invalid exposure config")
+
+// ExposureType is the type of the Services exposing an instance outside of the Kubernetes cluster.
+type ExposureType string
+
+const (
+	// ExposureNone keeps the instance reachable from inside the cluster only.
+	ExposureNone ExposureType = ""
+	// ExposureLoadBalancer exposes the instance through LoadBalancer Services, e.g. provided by cloud-provider-kind.
+	ExposureLoadBalancer ExposureType = "LoadBalancer"
+	// ExposureNodePort exposes the instance on a port of every node.
+	ExposureNodePort ExposureType = "NodePort"
+)
+
+const (
+	exportExternalConnectionInfo = "ExternalConnection"
+	exposureComponent            = "expose"
+	minNodePort                  = 30000
+	maxNodePort                  = 32767
+)
+
+// ExposureConfig exposes the primary and the sentinels of a [ModeReplication] instance outside of the Kubernetes
+// cluster, e.g. to connect from a workstation to a kind cluster. The external addresses are added to the instance
+// certificate so that clients can verify it, and the endpoints are exported as the `<name>ExternalConnection` stack
+// output.
+//
+// A LoadBalancer exposure keeps external traffic on the node of the selected pod, so the NetworkPolicy sees the
+// client's address. A NodePort exposure accepts connections on every node instead, and kube-proxy masquerades the ones
+// it forwards to another node, so SourceRanges also have to cover the node addresses.
+type ExposureConfig struct {
+	Type ExposureType `json:"type"`
+	// Addresses are the extra IPs or DNS names clients connect through, such as the node IPs of a [ExposureNodePort]
+	// exposure. The first one is exported as the host of a [ExposureNodePort] exposure.
+	Addresses []string `json:"addresses"`
+	// SourceRanges are the CIDRs allowed to connect. They have no default, so that exposing an instance never opens it
+	// to the internet by accident.
+	SourceRanges []string `json:"sourceRanges"`
+	// NodePort and SentinelNodePort pin the ports of a [ExposureNodePort] exposure. Kubernetes picks them otherwise.
+	NodePort         int `json:"nodePort"`
+	SentinelNodePort int `json:"sentinelNodePort"`
+}
+
+// newDefaultExposureConfig returns the exposure config used when the stack config doesn't override it.
+func newDefaultExposureConfig() *ExposureConfig {
+	return &ExposureConfig{
+		Type: ExposureNone,
+	}
+}
+
+// enabled reports whether the instance is exposed outside of the cluster.
+func (e *ExposureConfig) enabled() bool {
+	return e.Type != ExposureNone
+}
+
+// validate checks the exposure type, the source ranges, and the node ports.
+func (e *ExposureConfig) validate() error {
+	if !slices.Contains([]ExposureType{ExposureNone, ExposureLoadBalancer, ExposureNodePort}, e.Type) {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidExposureConfig, e.Type)
+	}
+	if !e.enabled() {
+		return nil
+	}
+	if len(e.SourceRanges) == 0 {
+		return fmt.Errorf("%w: at least one source range is required", ErrInvalidExposureConfig)
+	}
+	for _, sourceRange := range e.SourceRanges {
+		_, _, err := net.ParseCIDR(sourceRange)
+		if err != nil {
+			return fmt.Errorf("%w: invalid source range %q", ErrInvalidExposureConfig, sourceRange)
+		}
+	}
+	if e.Type == ExposureNodePort && len(e.Addresses) == 0 {
+		return fmt.Errorf("%w: a NodePort exposure needs the address of a node", ErrInvalidExposureConfig)
+	}
+	for _, port := range []int{e.NodePort, e.SentinelNodePort} {
+		if port == 0 {
+			continue
+		}
+		if e.Type != ExposureNodePort {
+			return fmt.Errorf("%w: node ports require the NodePort type", ErrInvalidExposureConfig)
+		}
+		if port < minNodePort || port > maxNodePort {
+			return fmt.Errorf(
+				"%w: node port %d is outside %d-%d",
+				ErrInvalidExposureConfig,
+				port,
+				minNodePort,
+				maxNodePort,
+			)
+		}
+	}
+	if e.NodePort != 0 && e.NodePort == e.SentinelNodePort {
+		return fmt.Errorf("%w: nodePort and sentinelNodePort must differ", ErrInvalidExposureConfig)
+	}
+	return nil
+}
+
+// ExternalConnectionInfo is exported as the `<name>ExternalConnection` stack output of an exposed instance and tells
+// clients outside of the cluster how to reach it. Host and Port always point at the current primary.
+//
+// The sentinels answer with the in-cluster hostname of the primary, which external clients can't resolve. Since the
+// primary endpoint follows failovers, sentinel clients map every address in PrimaryAddresses to Host and Port, e.g.
+// with the NAT map of redis-py or the address remapping of go-redis and Lettuce.
+type ExternalConnectionInfo struct {
+	Host             string    `json:"host"`
+	Port             int       `json:"port"`
+	SentinelHost     string    `json:"sentinelHost"`
+	SentinelPort     int       `json:"sentinelPort"`
+	MasterSet        string    `json:"masterSet"`
+	PrimaryAddresses []string  `json:"primaryAddresses"`
+	TLSSecret        string    `json:"tlsSecret"`
+	CABundle         *CABundle `json:"caBundle"`
+}
+
+// externalEndpoint is the address a single exposed Service is reached through.
+type externalEndpoint struct {
+	Host string
+	Port int
+}
+
+// deployValkeyExternalServices deploys the Services exposing the current primary and the sentinels of an instance.
+// LoadBalancer Services are awaited until an ingress address is assigned, since the certificate needs it.
+func deployValkeyExternalServices(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]*corev1.Service, error) {
+	// A NodePort exposure is reached through a single configured node address, which mostly isn't the node of the
+	// selected pod, so only LoadBalancer Services keep traffic local.
+	trafficPolicy := "Local"
+	if spec.Expose.Type == ExposureNodePort {
+		trafficPolicy = "Cluster"
+	}
+
+	var services []*corev1.Service
+	for _, service := range []struct {
+		name     string
+		selector pulumi.StringMap
+		port     int
+		nodePort int
+	}{
+		{externalServiceName(name), newValkeyPrimarySelectorLabels(name, spec), valkeyPort, spec.Expose.NodePort},
+		{
+			externalSentinelServiceName(name),
+			newValkeyPodSelectorLabels(name, spec),
+			sentinelPort,
+			spec.Expose.SentinelNodePort,
+		},
+	} {
+		port := &corev1.ServicePortArgs{
+			Name:       pulumi.String("tcp"),
+			Port:       pulumi.Int(service.port),
+			TargetPort: pulumi.Int(service.port),
+			Protocol:   pulumi.String("TCP"),
+		}
+		if service.nodePort != 0 {
+			port.NodePort = pulumi.Int(service.nodePort)
+		}
+		serviceSpec := &corev1.ServiceSpecArgs{
+			Type:                  pulumi.String(spec.Expose.Type),
+			Selector:              service.selector,
+			Ports:                 corev1.ServicePortArray{port},
+			ExternalTrafficPolicy: pulumi.String(trafficPolicy),
+		}
+		if spec.Expose.Type == ExposureLoadBalancer {
+			serviceSpec.LoadBalancerSourceRanges = pulumi.ToStringArray(spec.Expose.SourceRanges)
+		}
+		svc, err := corev1.NewService(
+			ctx,
+			service.name,
+			&corev1.ServiceArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Name:        pulumi.String(service.name),
+					Namespace:   namespace.Metadata.Name(),
+					Labels:      newValkeyToolingLabels(name, exposureComponent),
+					Annotations: newExternalServiceAnnotations(spec),
+				},
+				Spec: serviceSpec,
+			},
+			pulumi.Provider(provider),
+			pulumi.DependsOn(deps),
+		)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, svc)
+	}
+	return services, nil
+}
+
+// newExternalServiceAnnotations replaces Pulumi's readiness check of the external Services. The selected pods only
+// start once the certificate exists, so waiting for endpoints would deadlock. LoadBalancer Services are awaited until
+// they have an ingress address instead.
+func newExternalServiceAnnotations(spec *InstanceSpec) pulumi.StringMap {
+	if spec.Expose.Type == ExposureLoadBalancer {
+		return pulumi.StringMap{"pulumi.com/waitFor": pulumi.String("jsonpath={.status.loadBalancer.ingress}")}
+	}
+	return pulumi.StringMap{"pulumi.com/skipAwait": pulumi.String("true")}
+}
+
+// newExternalAddresses returns the configured addresses followed by the ingress addresses of the external Services,
+// which the instance certificate has to cover.
+func newExternalAddresses(spec *InstanceSpec, services []*corev1.Service) pulumi.StringArrayOutput {
+	var statuses []interface{}
+	for _, service := range services {
+		statuses = append(statuses, service.Status)
+	}
+	return pulumi.All(statuses...).ApplyT(func(args []interface{}) []string {
+		addresses := slices.Clone(spec.Expose.Addresses)
+		for _, arg := range args {
+			status, ok := arg.(*corev1.ServiceStatus)
+			if !ok || status == nil {
+				continue
+			}
+			for _, address := range ingressAddresses(*status) {
+				if !slices.Contains(addresses, address) {
+					addresses = append(addresses, address)
+				}
+			}
+		}
+		return addresses
+	}).(pulumi.StringArrayOutput)
+}
+
+// newExternalEndpoint returns the address clients outside of the cluster reach an external Service through: its first
+// ingress address for [ExposureLoadBalancer], or the first configured address and the node port for
+// [ExposureNodePort].
+func newExternalEndpoint(spec *InstanceSpec, service *corev1.Service) pulumi.Output {
+	return pulumi.All(service.Spec, service.Status).ApplyT(func(args []interface{}) (externalEndpoint, error) {
+		serviceSpec := args[0].(corev1.ServiceSpec)
+		port := serviceSpec.Ports[0]
+		if spec.Expose.Type == ExposureNodePort {
+			if port.NodePort == nil {
+				return externalEndpoint{}, fmt.Errorf("%w: no node port was allocated", ErrInvalidExposureConfig)
+			}
+			return externalEndpoint{Host: spec.Expose.Addresses[0], Port: *port.NodePort}, nil
+		}
+		var addresses []string
+		if status, ok := args[1].(*corev1.ServiceStatus); ok && status != nil {
+			addresses = ingressAddresses(*status)
+		}
+		if len(addresses) == 0 {
+			return externalEndpoint{}, fmt.Errorf("%w: no ingress address was assigned", ErrInvalidExposureConfig)
+		}
+		return externalEndpoint{Host: addresses[0], Port: port.Port}, nil
+	})
+}
+
+// exportExternalConnectionInfoOutput exports the [ExternalConnectionInfo] of an exposed instance.
+func exportExternalConnectionInfoOutput(
+	ctx *pulumi.Context,
+	name string,
+	spec *InstanceSpec,
+	services []*corev1.Service,
+) {
+	info := pulumi.All(newExternalEndpoint(spec, services[0]), newExternalEndpoint(spec, services[1])).ApplyT(
+		func(args []interface{}) (interface{}, error) {
+			primary := args[0].(externalEndpoint)
+			sentinel := args[1].(externalEndpoint)
+			var primaryAddresses []string
+			for _, hostname := range nodeHostnames(name, spec) {
+				primaryAddresses = append(primaryAddresses, fmt.Sprintf("%s:%d", hostname, valkeyPort))
+			}
+			return toPlainValue(&ExternalConnectionInfo{
+				Host:             primary.Host,
+				Port:             primary.Port,
+				SentinelHost:     sentinel.Host,
+				SentinelPort:     sentinel.Port,
+				MasterSet:        sentinelMasterSet,
+				PrimaryAddresses: primaryAddresses,
+				TLSSecret:        clusterCertificateSecretName(name),
+				CABundle: &CABundle{
+					Namespace: spec.namespaceName(name),
+					Secret:    clusterCertificateSecretName(name),
+					Key:       caBundleKey,
+				},
+			})
+		},
+	)
+	ctx.Export(exportName(name, exportExternalConnectionInfo), info)
+}
+
+// LookupExternalConnectionInfo reads the [ExternalConnectionInfo] of the exposed instance called [name] from the stack
+// deploying it. The output resolves to a *ExternalConnectionInfo.
+func LookupExternalConnectionInfo(ref *pulumi.StackReference, name string) pulumi.Output {
+	return ref.GetOutput(pulumi.String(exportName(name, exportExternalConnectionInfo))).ApplyT(
+		func(value interface{}) (*ExternalConnectionInfo, error) {
+			info := &ExternalConnectionInfo{}
+			err := fromPulumiValue(value, info)
+			if err != nil {
+				return nil, err
+			}
+			return info, nil
+		},
+	)
+}
+
+// newValkeyAllowExternalPolicySpec allows the configured source ranges to reach Valkey and sentinel through the
+// external Services.
+func newValkeyAllowExternalPolicySpec(name string, spec *InstanceSpec) *networkingv1.NetworkPolicySpecArgs {
+	peers := networkingv1.NetworkPolicyPeerArray{}
+	for _, sourceRange := range spec.Expose.SourceRanges {
+		peers = append(peers, &networkingv1.NetworkPolicyPeerArgs{
+			IpBlock: &networkingv1.IPBlockArgs{
+				Cidr: pulumi.String(sourceRange),
+			},
+		})
+	}
+	return &networkingv1.NetworkPolicySpecArgs{
+		PodSelector: &metav1.LabelSelectorArgs{
+			MatchLabels: newValkeyPodSelectorLabels(name, spec),
+		},
+		PolicyTypes: pulumi.StringArray{pulumi.String("Ingress")},
+		Ingress: networkingv1.NetworkPolicyIngressRuleArray{
+			&networkingv1.NetworkPolicyIngressRuleArgs{
+				From:  peers,
+				Ports: newNetworkPolicyPorts(valkeyPort, sentinelPort),
+			},
+		},
+	}
+}
+
+// ingressAddresses returns the IPs and hostnames of a LoadBalancer Service's ingress.
+func ingressAddresses(status corev1.ServiceStatus) []string {
+	var addresses []string
+	if status.LoadBalancer == nil {
+		return addresses
+	}
+	for _, ingress := range status.LoadBalancer.Ingress {
+		switch {
+		case ingress.Ip != nil:
+			addresses = append(addresses, *ingress.Ip)
+		case ingress.Hostname != nil:
+			addresses = append(addresses, *ingress.Hostname)
+		}
+	}
+	return addresses
+}
+
+// externalServiceName returns the name of the Service exposing the current primary of an instance.
+func externalServiceName(name string) string {
+	return fmt.Sprintf("%s-external", name)
+}
+
+// externalSentinelServiceName returns the name of the Service exposing the sentinels of an instance.
+func externalSentinelServiceName(name string) string {
+	return fmt.Sprintf("%s-sentinel-external", name)
+}
@@ -0,0 +1,101 @@
+package valkey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExposureConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		expose *ExposureConfig
+		want   error
+	}{
+		{
+			name:   "not exposed",
+			expose: newDefaultExposureConfig(),
+		},
+		{
+			name:   "unknown type",
+			expose: &ExposureConfig{Type: "Ingress", SourceRanges: []string{"10.0.0.0/8"}},
+			want:   ErrInvalidExposureConfig,
+		},
+		{
+			name:   "load balancer",
+			expose: &ExposureConfig{Type: ExposureLoadBalancer, SourceRanges: []string{"10.0.0.0/8", "fd00::/8"}},
+		},
+		{
+			name:   "no source ranges",
+			expose: &ExposureConfig{Type: ExposureLoadBalancer},
+			want:   ErrInvalidExposureConfig,
+		},
+		{
+			name:   "source range without a prefix length",
+			expose: &ExposureConfig{Type: ExposureLoadBalancer, SourceRanges: []string{"10.0.0.1"}},
+			want:   ErrInvalidExposureConfig,
+		},
+		{
+			name: "node ports",
+			expose: &ExposureConfig{
+				Type:             ExposureNodePort,
+				Addresses:        []string{"172.18.0.2"},
+				SourceRanges:     []string{"172.18.0.0/16"},
+				NodePort:         30379,
+				SentinelNodePort: 32379,
+			},
+		},
+		{
+			name:   "node port without an address",
+			expose: &ExposureConfig{Type: ExposureNodePort, SourceRanges: []string{"172.18.0.0/16"}},
+			want:   ErrInvalidExposureConfig,
+		},
+		{
+			name: "node port of a load balancer",
+			expose: &ExposureConfig{
+				Type:         ExposureLoadBalancer,
+				SourceRanges: []string{"10.0.0.0/8"},
+				NodePort:     30379,
+			},
+			want: ErrInvalidExposureConfig,
+		},
+		{
+			name: "node port out of range",
+			expose: &ExposureConfig{
+				Type:         ExposureNodePort,
+				Addresses:    []string{"172.18.0.2"},
+				SourceRanges: []string{"172.18.0.0/16"},
+				NodePort:     6379,
+			},
+			want: ErrInvalidExposureConfig,
+		},
+		{
+			name: "sentinel node port out of range",
+			expose: &ExposureConfig{
+				Type:             ExposureNodePort,
+				Addresses:        []string{"172.18.0.2"},
+				SourceRanges:     []string{"172.18.0.0/16"},
+				SentinelNodePort: maxNodePort + 1,
+			},
+			want: ErrInvalidExposureConfig,
+		},
+		{
+			name: "shared node port",
+			expose: &ExposureConfig{
+				Type:             ExposureNodePort,
+				Addresses:        []string{"172.18.0.2"},
+				SourceRanges:     []string{"172.18.0.0/16"},
+				NodePort:         30379,
+				SentinelNodePort: 30379,
+			},
+			want: ErrInvalidExposureConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.expose.validate()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package valkey
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidFunctionLibrary is returned when a function library can't be read or doesn't declare its name.
+var ErrInvalidFunctionLibrary = fmt.Errorf("invalid function library")
+
+const (
+	exportFunctions          = "Functions"
+	functionsComponent       = "functions"
+	functionsDriftComponent  = "functions-drift"
+	functionsMountPath       = "/functions"
+	functionsVolume          = "functions"
+	functionLibraryExtension = ".lua"
+	functionsHashLength      = 10
+)
+
+var (
+	// functionLibraryShebang matches the first line of a library, e.g. `#!lua name=ratelimit`.
+	functionLibraryShebang = regexp.MustCompile(`^#!lua name=([A-Za-z0-9_]+)\s*$`)
+	// functionRegistration matches both the positional and the table form of `server.register_function`.
+	functionRegistration = regexp.MustCompile(
+		`(?:server|redis)\.register_function\s*\(?\s*(?:\{[^}]*?function_name\s*=\s*)?['"]([^'"]+)['"]`,
+	)
+)
+
+// functionsLoadScript loads every library on the primaries with `FUNCTION LOAD REPLACE`, which the primaries replicate
+// to their replicas. It then runs [functionsVerifyScript].
+const functionsLoadScript = `for host in %[1]s; do
+  if ! cli -h "${host}" -p %[2]d INFO replication | grep -q '^role:master'; then
+    continue
+  fi
+  for library in %[3]s/*%[4]s; do
+    cli -h "${host}" -p %[2]d -x FUNCTION LOAD REPLACE <"${library}"
+    echo "loaded $(basename "${library}" %[4]s) on ${host}"
+  done
+done
+`
+
+// functionsVerifyScript compares the code of every library loaded on the primaries with the code in the ConfigMap, and
+// fails when a library is missing or was changed since it was loaded.
+const functionsVerifyScript = `drift=0
+for host in %[1]s; do
+  if ! cli -h "${host}" -p %[2]d INFO replication | grep -q '^role:master'; then
+    continue
+  fi
+  for library in %[3]s/*%[4]s; do
+    name="$(basename "${library}" %[4]s)"
+    loaded="$(cli -h "${host}" -p %[2]d FUNCTION LIST WITHCODE LIBRARYNAME "${name}" | sed -n '/^library_code$/,$p' | tail -n +2)"
+    if [ "${loaded}" != "$(cat "${library}")" ]; then
+      echo "DRIFT: library ${name} on ${host} differs from the deployed code"
+      drift=1
+    fi
+  done
+done
+if [ "${drift}" -ne 0 ]; then
+  exit 1
+fi
+echo "function libraries match the deployed code"
+`
+
+// FunctionsConfig deploys the Valkey Functions libraries found in Directory, one `.lua` file per library starting
+// with a `#!lua name=<library>` line. The libraries are loaded after every update that changes them, and checked for
+// drift on DriftSchedule.
+// SEE: https://valkey.io/topics/functions-intro/
+type FunctionsConfig struct {
+	// Directory is relative to the Pulumi project. Leave empty to disable.
+	Directory     string `json:"directory"`
+	DriftSchedule string `json:"driftSchedule"`
+}
+
+// functionLibrary is a library read from [FunctionsConfig.Directory].
+type functionLibrary struct {
+	Name      string
+	Code      string
+	Functions []string
+}
+
+// newDefaultFunctionsConfig returns the disabled functions config used when the stack config doesn't enable it.
+func newDefaultFunctionsConfig() *FunctionsConfig {
+	return &FunctionsConfig{
+		DriftSchedule: "*/30 * * * *",
+	}
+}
+
+// validate checks that a drift schedule is set when functions are deployed. The libraries themselves are read and
+// checked when the instance is deployed.
+func (c *FunctionsConfig) validate() error {
+	if c.Directory != "" && c.DriftSchedule == "" {
+		return fmt.Errorf("%w: a drift schedule is required", ErrInvalidFunctionLibrary)
+	}
+	return nil
+}
+
+// loadFunctionLibraries reads the libraries in the functions directory, sorted by library name.
+func (c *FunctionsConfig) loadFunctionLibraries() ([]*functionLibrary, error) {
+	files, err := filepath.Glob(filepath.Join(c.Directory, "*"+functionLibraryExtension))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: no %s files in %s", ErrInvalidFunctionLibrary, functionLibraryExtension, c.Directory)
+	}
+
+	seen := map[string]bool{}
+	var libraries []*functionLibrary
+	for _, file := range files {
+		code, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		library, err := newFunctionLibrary(string(code))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if seen[library.Name] {
+			return nil, fmt.Errorf("%w: library %s is declared twice", ErrInvalidFunctionLibrary, library.Name)
+		}
+		seen[library.Name] = true
+		libraries = append(libraries, library)
+	}
+	sort.Slice(libraries, func(i, j int) bool {
+		return libraries[i].Name < libraries[j].Name
+	})
+	return libraries, nil
+}
+
+// newFunctionLibrary parses the name and the registered functions of a library.
+func newFunctionLibrary(code string) (*functionLibrary, error) {
+	shebang, _, _ := strings.Cut(code, "\n")
+	match := functionLibraryShebang.FindStringSubmatch(shebang)
+	if match == nil {
+		return nil, fmt.Errorf("%w: the first line must be `#!lua name=<library>`", ErrInvalidFunctionLibrary)
+	}
+	library := &functionLibrary{
+		Name:      match[1],
+		Code:      code,
+		Functions: []string{},
+	}
+	for _, registration := range functionRegistration.FindAllStringSubmatch(code, -1) {
+		library.Functions = append(library.Functions, registration[1])
+	}
+	if len(library.Functions) == 0 {
+		return nil, fmt.Errorf("%w: library %s registers no functions", ErrInvalidFunctionLibrary, library.Name)
+	}
+	return library, nil
+}
+
+// deployValkeyFunctions ships the function libraries of an instance in a ConfigMap, loads them with a Job, and checks
+// them for drift with a CronJob. The Job is named after a hash of the libraries, so that it runs again whenever they
+// change, and Pulumi waits for it to complete.
+func deployValkeyFunctions(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	libraries, err := spec.Functions.loadFunctionLibraries()
+	if err != nil {
+		return nil, err
+	}
+
+	configMapName := functionsConfigMapName(name)
+	data := pulumi.StringMap{}
+	hash := sha256.New()
+	for _, library := range libraries {
+		data[library.Name+functionLibraryExtension] = pulumi.String(library.Code)
+		hash.Write([]byte(library.Code))
+	}
+	configMap, err := corev1.NewConfigMap(
+		ctx,
+		configMapName,
+		&corev1.ConfigMapArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(configMapName),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newValkeyInstanceLabels(name),
+			},
+			Data: data,
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The hosts are part of the hash, so that the libraries are loaded onto the new pods when the instance is scaled.
+	hosts := strings.Join(nodeHostnames(name, spec), " ")
+	hash.Write([]byte(hosts))
+	verify := fmt.Sprintf(functionsVerifyScript, hosts, valkeyPort, functionsMountPath, functionLibraryExtension)
+	load := fmt.Sprintf(functionsLoadScript, hosts, valkeyPort, functionsMountPath, functionLibraryExtension) + verify
+	sum := fmt.Sprintf("%x", hash.Sum(nil))[:functionsHashLength]
+	jobName := revisionedJobName(fmt.Sprintf("%s-%s", name, functionsComponent), sum)
+	job, err := batchv1.NewJob(
+		ctx,
+		fmt.Sprintf("%s-%s", name, functionsComponent),
+		&batchv1.JobArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(jobName),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newValkeyToolingLabels(name, functionsComponent),
+			},
+			Spec: newValkeyFunctionsJobSpecArgs(name, functionsComponent, load),
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(append(deps, configMap)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := newValkeyToolingLabels(name, functionsDriftComponent)
+	cronJob, err := batchv1.NewCronJob(
+		ctx,
+		fmt.Sprintf("%s-%s", name, functionsDriftComponent),
+		&batchv1.CronJobArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(fmt.Sprintf("%s-%s", name, functionsDriftComponent)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    labels,
+			},
+			Spec: &batchv1.CronJobSpecArgs{
+				Schedule:                   pulumi.String(spec.Functions.DriftSchedule),
+				ConcurrencyPolicy:          pulumi.String("Forbid"),
+				SuccessfulJobsHistoryLimit: pulumi.Int(1),
+				FailedJobsHistoryLimit:     pulumi.Int(3),
+				JobTemplate: &batchv1.JobTemplateSpecArgs{
+					Spec: newValkeyFunctionsJobSpecArgs(name, functionsDriftComponent, verify),
+				},
+			},
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn([]pulumi.Resource{job}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	exportFunctionsOutput(ctx, name, libraries)
+	return []pulumi.Resource{configMap, job, cronJob}, nil
+}
+
+// newValkeyFunctionsJobSpecArgs returns the spec of a Job running a script against the libraries mounted from the
+// functions ConfigMap.
+func newValkeyFunctionsJobSpecArgs(name string, component string, script string) *batchv1.JobSpecArgs {
+	return &batchv1.JobSpecArgs{
+		ActiveDeadlineSeconds: pulumi.Int(300),
+		BackoffLimit:          pulumi.Int(1),
+		Template: &corev1.PodTemplateSpecArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Labels: newValkeyToolingLabels(name, component),
+			},
+			Spec: &corev1.PodSpecArgs{
+				RestartPolicy:   pulumi.String("Never"),
+				SecurityContext: newValkeyToolingPodSecurityContext(),
+				Containers: corev1.ContainerArray{
+					newValkeyToolingContainer(
+						name,
+						component,
+						newValkeyToolingScript(operatorUsername, script),
+						corev1.EnvVarArray{
+							newSecretKeyEnvVar(valkeyCLIAuthEnv, aclSecretName(name), operatorSecretKey),
+						},
+						&corev1.VolumeMountArgs{
+							Name:      pulumi.String(functionsVolume),
+							MountPath: pulumi.String(functionsMountPath),
+							ReadOnly:  pulumi.Bool(true),
+						},
+					),
+				},
+				Volumes: append(
+					newValkeyToolingVolumes(name),
+					&corev1.VolumeArgs{
+						Name: pulumi.String(functionsVolume),
+						ConfigMap: &corev1.ConfigMapVolumeSourceArgs{
+							Name: pulumi.String(functionsConfigMapName(name)),
+						},
+					},
+				),
+			},
+		},
+	}
+}
+
+// exportFunctionsOutput exports the functions registered by every library of an instance, keyed by library name.
+func exportFunctionsOutput(ctx *pulumi.Context, name string, libraries []*functionLibrary) {
+	functions := pulumi.Map{}
+	for _, library := range libraries {
+		functions[library.Name] = pulumi.ToStringArray(library.Functions)
+	}
+	ctx.Export(exportName(name, exportFunctions), functions)
+}
+
+// functionsConfigMapName returns the name of the ConfigMap holding an instance's function libraries.
+func functionsConfigMapName(name string) string {
+	return fmt.Sprintf("%s-%s", name, functionsComponent)
+}
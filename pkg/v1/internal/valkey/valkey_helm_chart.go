@@ -0,0 +1,546 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	helmv4 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v4"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	clusterAppLabel = "valkey"
+	chartName       = "valkey"
+	chartRepo       = "oci://registry-1.docker.io/bitnamicharts/valkey"
+	chartVersion    = "3.0.16"
+	imageDigest     = "sha256:0384ca2eec63789450b2e07a00f377c2c9d0b548c2e346e1003bc0dd629fa71a"
+	imageRepository = "docker.io/bitnami/valkey"
+	tlsVolumeName   = "valkey-certificates"
+)
+
+// DeployValkeyCluster sets up the required resources needed to run the Valkey instance called [name] including a
+// namespace, TLS certificate, ACLs, and Helm chart. Every name is derived from [name] so that several instances can be
+// deployed to the same Kubernetes cluster.
+//
+// [opts] may only add dependencies with pulumi.DependsOn, e.g. on cert-manager, which every resource of the instance
+// waits for.
+func DeployValkeyCluster(
+	ctx *pulumi.Context,
+	provider *kubernetes.Provider,
+	name string,
+	spec *InstanceSpec,
+	opts ...pulumi.ResourceOption,
+) ([]pulumi.Resource, error) {
+	err := spec.validate()
+	if err != nil {
+		return nil, fmt.Errorf("valkey instance %s: %w", name, err)
+	}
+	err = spec.validateCertificateUsers(spec.certificateCommonNames(name))
+	if err != nil {
+		return nil, fmt.Errorf("valkey instance %s: %w", name, err)
+	}
+	deps, err := newInstanceDependencies(opts)
+	if err != nil {
+		return nil, fmt.Errorf("valkey instance %s: %w", name, err)
+	}
+	if spec.Backend != BackendValkey {
+		return nil, fmt.Errorf(
+			"valkey instance %s: %w: %s instances are deployed by their own package",
+			name,
+			ErrUnknownBackend,
+			spec.Backend,
+		)
+	}
+	namespaceName := spec.namespaceName(name)
+
+	namespace, err := deployValkeyClusterNamespace(
+		ctx,
+		name,
+		namespaceName,
+		provider,
+		[]pulumi.Resource{},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var externalServices []*corev1.Service
+	externalAddresses := pulumi.StringArrayOutput{}
+	if spec.Expose.enabled() {
+		externalServices, err = deployValkeyExternalServices(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(deps, namespace),
+		)
+		if err != nil {
+			return nil, err
+		}
+		externalAddresses = newExternalAddresses(spec, externalServices)
+	}
+
+	cert, err := deployValkeyClusterCertificate(
+		ctx,
+		name,
+		namespace,
+		namespaceName,
+		externalAddresses,
+		provider,
+		append(deps, namespace),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	configContent, err := newValkeyCommonConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+	aclContent, err := newValkeyUserACL(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	aclSecret, err := deployValkeyClusterACLSecret(
+		ctx,
+		name,
+		namespace,
+		spec,
+		aclContent,
+		provider,
+		append(deps, namespace),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	chartDeps := append(deps, namespace, cert, aclSecret)
+	if spec.labelsRoles() {
+		roleLabeler, err := deployValkeyRoleLabelerRBAC(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(deps, namespace),
+		)
+		if err != nil {
+			return nil, err
+		}
+		chartDeps = append(chartDeps, roleLabeler...)
+	}
+	if spec.Backup.Enabled || spec.Restore.Snapshot != "" {
+		backupSecret, err := deployValkeyBackupSecret(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(deps, namespace),
+		)
+		if err != nil {
+			return nil, err
+		}
+		chartDeps = append(chartDeps, backupSecret)
+
+		if spec.Backup.LocalMinio {
+			minio, err := deployValkeyBackupMinio(
+				ctx,
+				name,
+				namespace,
+				provider,
+				append(deps, backupSecret),
+			)
+			if err != nil {
+				return nil, err
+			}
+			chartDeps = append(chartDeps, minio...)
+		}
+	}
+
+	chart, err := deployValkeyClusterHelmChart(
+		ctx,
+		name,
+		namespace,
+		spec,
+		configContent,
+		provider,
+		chartDeps,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := deployValkeyUserCredentialsSecrets(
+		ctx,
+		name,
+		spec.Users,
+		provider,
+		append(deps, aclSecret),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	clientCerts, err := deployValkeyClientCertificates(
+		ctx,
+		name,
+		spec,
+		provider,
+		deps,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := deployValkeyNetworkPolicies(
+		ctx,
+		name,
+		namespace,
+		spec,
+		provider,
+		append(deps, namespace),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := append([]pulumi.Resource{namespace, cert, aclSecret, chart}, credentials...)
+	if spec.labelsRoles() {
+		primaryService, err := deployValkeyPrimaryService(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(deps, chart),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, primaryService)
+	}
+	resources = append(resources, clientCerts...)
+	resources = append(resources, policies...)
+	var restartLock []pulumi.Resource
+	if spec.restartsPods() {
+		lock, err := deployValkeyRestartLock(ctx, name, namespace, provider, append(deps, namespace))
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, lock)
+		restartLock = append(restartLock, lock)
+	}
+	// With managed upgrades Pulumi doesn't wait for the pods, so the tasks needing them wait for the upgrade instead.
+	var ready pulumi.Resource = chart
+	if spec.managedUpgrade() {
+		upgrade, err := deployValkeyUpgrade(
+			ctx,
+			name,
+			namespace,
+			spec,
+			configContent,
+			provider,
+			append(append(deps, chart), restartLock...),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, upgrade...)
+		ready = upgrade[len(upgrade)-1]
+	}
+	if spec.Backup.Enabled {
+		backups, err := deployValkeyBackupCronJob(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(deps, ready),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, backups)
+	}
+	if len(spec.Users) > 0 {
+		smokeTest, err := deployValkeyACLSmokeTest(
+			ctx,
+			name,
+			namespace,
+			spec,
+			aclContent,
+			provider,
+			append(deps, ready),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, smokeTest...)
+	}
+	if spec.Functions.Directory != "" {
+		functions, err := deployValkeyFunctions(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(deps, ready),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, functions...)
+	}
+	if spec.Mode == ModeCluster || spec.Replicas > 1 {
+		pdb, err := deployValkeyPodDisruptionBudget(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(deps, ready),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, pdb)
+	}
+	if spec.reloadsCertificates() {
+		reload, err := deployValkeyCertReload(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(append(deps, ready), restartLock...),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, reload...)
+	}
+	if spec.Metrics.Enabled {
+		metrics, err := deployValkeyMetrics(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(deps, ready),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, metrics...)
+	}
+	if spec.Benchmark.Enabled {
+		benchmark, err := deployValkeyBenchmark(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(deps, ready),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, benchmark...)
+	}
+	if spec.Proxy.Enabled {
+		proxy, err := deployValkeyProxy(
+			ctx,
+			name,
+			namespace,
+			spec,
+			provider,
+			append(deps, ready),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, proxy...)
+	}
+
+	err = ExportInstanceOutputs(ctx, name, spec, newConnectionInfo(name, spec))
+	if err != nil {
+		return nil, err
+	}
+	if spec.Expose.enabled() {
+		exportExternalConnectionInfoOutput(ctx, name, spec, externalServices)
+		for _, service := range externalServices {
+			resources = append(resources, service)
+		}
+	}
+	return resources, nil
+}
+
+// deployValkeyClusterHelmChart deploys Valkey using the bitnami Helm chart matching the mode: the `valkey` chart for
+// [ModeReplication] and the `valkey-cluster` chart for [ModeCluster].
+func deployValkeyClusterHelmChart(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	configContent string,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (pulumi.Resource, error) {
+	args := newValkeyClusterHelmChartArgs(name, namespace, spec, configContent)
+	if spec.Mode == ModeCluster {
+		args = newValkeyShardedHelmChartArgs(name, namespace, spec, configContent)
+	}
+
+	chart, err := helmv4.NewChart(
+		ctx,
+		name,
+		args,
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return chart, nil
+}
+
+// newValkeyClusterHelmChartArgs constructs the Helm chart values needed to deploy Valkey to k8s.
+func newValkeyClusterHelmChartArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	configContent string,
+) *helmv4.ChartArgs {
+	persistence, retention := newValkeyPersistenceValues(spec.Persistence)
+	updateStrategy, annotations := newValkeyUpdateStrategyValues(spec)
+	chartArgs := &helmv4.ChartArgs{
+		Chart:     pulumi.String(chartRepo),
+		Namespace: namespace.Metadata.Name(),
+		Version:   pulumi.String(chartVersion),
+		Values: pulumi.Map{
+			"architecture": pulumi.String("replication"),
+			"auth": pulumi.Map{
+				"enabled": pulumi.Bool(true),
+				// The password for the default user
+				"password": pulumi.ToSecret(pulumi.String(spec.DefaultUserCredentials)),
+			},
+			"commonConfiguration": pulumi.String(configContent),
+			"fullnameOverride":    pulumi.String(name),
+			// NetworkPolicies are managed by deployValkeyNetworkPolicies instead of the chart.
+			"networkPolicy": pulumi.Map{
+				"enabled": pulumi.Bool(false),
+			},
+			"image": pulumi.Map{
+				"digest": pulumi.String(imageDigest),
+			},
+			// With sentinel enabled the chart only renders the `node` StatefulSet, which is configured through the
+			// `replica` values.
+			"replica": pulumi.Map{
+				"replicaCount":                         pulumi.Int(spec.Replicas),
+				"resources":                            spec.Sizing.ValkeyResources().values(),
+				"initContainers":                       newValkeyInitContainers(name, spec),
+				"extraVolumes":                         newValkeyExtraVolumes(name, spec),
+				"extraVolumeMounts":                    newValkeyExtraVolumeMounts(spec),
+				"persistence":                          persistence,
+				"persistentVolumeClaimRetentionPolicy": retention,
+				"sidecars":                             newValkeySidecars(name, spec),
+				"podAntiAffinityPreset":                pulumi.String(spec.Placement.AntiAffinity),
+				"topologySpreadConstraints":            newValkeyTopologySpreadConstraints(name, spec),
+				"nodeSelector":                         newValkeyNodeSelectorValues(spec),
+				"tolerations":                          newValkeyTolerationValues(spec),
+				"updateStrategy":                       updateStrategy,
+				"annotations":                          annotations,
+				// The PodDisruptionBudget is managed by deployValkeyPodDisruptionBudget instead of the chart.
+				"pdb": pulumi.Map{
+					"create": pulumi.Bool(false),
+				},
+			},
+			"sentinel": pulumi.Map{
+				"enabled":               pulumi.Bool(true),
+				"quorum":                pulumi.Int(spec.Sentinel.quorum(spec.Replicas)),
+				"downAfterMilliseconds": pulumi.Int(spec.Sentinel.DownAfterMilliseconds),
+				"failoverTimeout":       pulumi.Int(spec.Sentinel.FailoverTimeout),
+				"parallelSyncs":         pulumi.Int(spec.Sentinel.ParallelSyncs),
+				"image": pulumi.Map{
+					"digest": pulumi.String("sha256:071cb353bc17f27492655c710386d5e3afc5c36d8057ea5d48c5886da6f1bc3a"),
+				},
+				"resources": spec.Sizing.SentinelResources().values(),
+			},
+			"tls": pulumi.Map{
+				"enabled":         pulumi.Bool(true),
+				"authClients":     pulumi.Bool(spec.TLS.AuthClients),
+				"existingSecret":  pulumi.String(clusterCertificateSecretName(name)),
+				"certFilename":    pulumi.String("tls.crt"),
+				"certKeyFilename": pulumi.String("tls.key"),
+				"certCAFilename":  pulumi.String("ca.crt"),
+			},
+		},
+	}
+	if spec.Metrics.Enabled {
+		values := chartArgs.Values.(pulumi.Map)
+		values["metrics"] = newValkeyMetricsValues(name, spec)
+		values["sentinel"].(pulumi.Map)["configuration"] = pulumi.ToSecret(pulumi.String(newValkeySentinelMetricsUser(spec)))
+	}
+	if spec.labelsRoles() {
+		// The role labeler sidecar labels its own pod, so the pods run as a ServiceAccount allowed to do so.
+		values := chartArgs.Values.(pulumi.Map)
+		values["serviceAccount"] = pulumi.Map{
+			"create":                       pulumi.Bool(false),
+			"name":                         pulumi.String(toolingServiceAccountName(name, roleLabelerComponent)),
+			"automountServiceAccountToken": pulumi.Bool(true),
+		}
+		values["replica"].(pulumi.Map)["automountServiceAccountToken"] = pulumi.Bool(true)
+	}
+	return chartArgs
+}
+
+// newValkeySidecars returns the sidecars added to every Valkey pod of the instance.
+func newValkeySidecars(name string, spec *InstanceSpec) pulumi.Array {
+	sidecars := pulumi.Array{
+		newValkeyACLReloaderSidecar(name),
+	}
+	if spec.labelsRoles() {
+		sidecars = append(sidecars, newValkeyRoleLabelerSidecar(name))
+	}
+	return sidecars
+}
+
+// newValkeyExtraVolumes returns the volumes added to every Valkey pod of the instance.
+func newValkeyExtraVolumes(name string, spec *InstanceSpec) pulumi.Array {
+	volumes := newValkeyACLVolumes(name)
+	if spec.labelsRoles() {
+		volumes = append(volumes, pulumi.Map{
+			"name":     pulumi.String(toolingBinVolume),
+			"emptyDir": pulumi.Map{},
+		})
+	}
+	if len(spec.Modules) > 0 {
+		volumes = append(volumes, newValkeyModuleVolume())
+	}
+	if spec.reloadsCertificates() {
+		volumes = append(volumes, pulumi.Map{
+			"name":     pulumi.String(tlsChecksumVolume),
+			"emptyDir": pulumi.Map{},
+		})
+	}
+	return volumes
+}
+
+// newValkeyExtraVolumeMounts returns the volume mounts added to the Valkey container of every pod of the instance.
+func newValkeyExtraVolumeMounts(spec *InstanceSpec) pulumi.Array {
+	mounts := newValkeyACLVolumeMounts()
+	if len(spec.Modules) > 0 {
+		mounts = append(mounts, newValkeyModuleVolumeMount())
+	}
+	if spec.reloadsCertificates() {
+		mounts = append(mounts, pulumi.Map{
+			"name":      pulumi.String(tlsChecksumVolume),
+			"mountPath": pulumi.String(tlsChecksumMountPath),
+			"readOnly":  pulumi.Bool(true),
+		})
+	}
+	return mounts
+}
@@ -0,0 +1,20 @@
+package valkey
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ErrUnpinnedImage is returned when an instance uses an image that isn't pinned by a digest.
+var ErrUnpinnedImage = fmt.Errorf("image isn't pinned by digest")
+
+// imageDigestPattern matches the digests images are pinned by, like [imageDigest].
+var imageDigestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// validateImageDigest checks that [digest] pins [repository].
+func validateImageDigest(repository string, digest string) error {
+	if !imageDigestPattern.MatchString(digest) {
+		return fmt.Errorf("%w: %s needs a sha256 digest, got %q", ErrUnpinnedImage, repository, digest)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package valkey
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"reflect"
+	"regexp"
+)
+
+// ErrInvalidInstanceConfig is returned when the stack config declares an instance that can't be deployed.
+var ErrInvalidInstanceConfig = fmt.Errorf("invalid valkey instance config")
+
+const (
+	configInstances = "valkey:instances"
+	// maxNameLength is the length limit of a DNS-1123 label, which the instance name and namespace become.
+	maxNameLength = 63
+)
+
+// dns1123Label matches the names Kubernetes accepts for namespaces and Services.
+var dns1123Label = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// InstanceSpec describes a single, independently named Valkey instance. Every instance gets its own namespace,
+// certificate, ACLs, Helm release, and stack outputs.
+type InstanceSpec struct {
+	// Namespace defaults to the instance name.
+	Namespace string  `json:"namespace"`
+	Backend   Backend `json:"backend"`
+	Mode      Mode    `json:"mode"`
+	// Replicas is the number of Valkey pods, each running a sentinel, in [ModeReplication].
+	Replicas                int                `json:"replicas"`
+	Sharding                *ShardingConfig    `json:"sharding"`
+	Persistence             *PersistenceConfig `json:"persistence"`
+	Sizing                  *SizingConfig      `json:"sizing"`
+	Server                  *ServerConfig      `json:"server"`
+	Placement               *PlacementConfig   `json:"placement"`
+	Sentinel                *SentinelConfig    `json:"sentinel"`
+	DefaultUserCredentials  string             `json:"defaultUserCredentials"`
+	SentinelUserCredentials string             `json:"sentinelUserCredentials"`
+	ReplicaUserCredentials  string             `json:"replicaUserCredentials"`
+	OperatorUserCredentials string             `json:"operatorUserCredentials"`
+	Users                   []*valkeyUser      `json:"users"`
+	Metrics                 *MetricsConfig     `json:"metrics"`
+	// AllowedClients lists the pods allowed to connect to the instance. All other ingress is denied.
+	AllowedClients []*AllowedClient `json:"allowedClients"`
+	Backup         *BackupConfig    `json:"backup"`
+	Restore        *RestoreConfig   `json:"restore"`
+	TLS            *TLSConfig       `json:"tls"`
+	Functions      *FunctionsConfig `json:"functions"`
+	// Modules are loaded into every Valkey server of the instance.
+	Modules []Module `json:"modules"`
+	// ModuleImageDigest pins the [moduleImage] the module libraries are copied from. It's required with Modules.
+	ModuleImageDigest string `json:"moduleImageDigest"`
+	// CertReload restarts the Valkey pods after cert-manager renews the instance certificate.
+	CertReload *CertReloadConfig `json:"certReload"`
+	// Upgrade fails the primary over before chart and image updates restart it.
+	Upgrade *UpgradeConfig `json:"upgrade"`
+	// Expose makes the primary reachable from outside of the Kubernetes cluster.
+	Expose *ExposureConfig `json:"expose"`
+	// Benchmark load-tests the instance with valkey-benchmark and exports the results.
+	Benchmark *BenchmarkConfig `json:"benchmark"`
+	// Proxy pools client connections to the primary behind a single stable endpoint.
+	Proxy *ProxyConfig `json:"proxy"`
+}
+
+// InstanceConfig is an entry of the `valkey:instances` stack config list.
+type InstanceConfig struct {
+	Name string `json:"name"`
+	InstanceSpec
+}
+
+// NewInstanceConfigsFromStackConfig reads the Valkey instances declared in the `valkey:instances` stack config list.
+// Settings an entry omits fall back to the defaults of [newDefaultInstanceSpec].
+//
+// The list holds the passwords of the instances, so it has to be stored as a Pulumi secret, e.g. with
+// `pulumi config set --secret --path 'valkey:instances[0].defaultUserCredentials'`. Every value is still decoded, but
+// the passwords are wrapped with pulumi.ToSecret wherever they reach chart values or Secrets.
+func NewInstanceConfigsFromStackConfig(ctx *pulumi.Context) ([]*InstanceConfig, error) {
+	var entries []json.RawMessage
+	_, err := config.GetSecretObject(ctx, configInstances, &entries)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 && !ctx.IsConfigSecret(configInstances) {
+		return nil, fmt.Errorf(
+			"%w: %s holds passwords and must be set with `pulumi config set --secret`",
+			ErrInvalidInstanceConfig,
+			configInstances,
+		)
+	}
+
+	seen := map[string]bool{}
+	namespaces := map[string]string{}
+	instances := make([]*InstanceConfig, 0, len(entries))
+	for _, entry := range entries {
+		instance := &InstanceConfig{InstanceSpec: *newDefaultInstanceSpec()}
+		err = json.Unmarshal(entry, instance)
+		if err != nil {
+			return nil, err
+		}
+		if instance.Name == "" {
+			return nil, fmt.Errorf("%w: instances must be named", ErrInvalidInstanceConfig)
+		}
+		if seen[instance.Name] {
+			return nil, fmt.Errorf("%w: duplicate instance %s", ErrInvalidInstanceConfig, instance.Name)
+		}
+		seen[instance.Name] = true
+		for _, label := range []string{instance.Name, instance.namespaceName(instance.Name)} {
+			if len(label) > maxNameLength || !dns1123Label.MatchString(label) {
+				return nil, fmt.Errorf(
+					"%w: %q must be a lowercase DNS-1123 label of at most %d characters",
+					ErrInvalidInstanceConfig,
+					label,
+					maxNameLength,
+				)
+			}
+		}
+		namespace := instance.namespaceName(instance.Name)
+		if other, ok := namespaces[namespace]; ok {
+			return nil, fmt.Errorf(
+				"%w: instances %s and %s share the namespace %s",
+				ErrInvalidInstanceConfig,
+				other,
+				instance.Name,
+				namespace,
+			)
+		}
+		namespaces[namespace] = instance.Name
+		instances = append(instances, instance)
+	}
+
+	// Every instance issues its certificates from the same cluster issuer, so a certificate of one instance must not
+	// authenticate as a user of another.
+	for _, instance := range instances {
+		var commonNames []string
+		for _, other := range instances {
+			commonNames = append(commonNames, other.certificateCommonNames(other.Name)...)
+			if other == instance {
+				continue
+			}
+			for _, user := range other.Users {
+				commonNames = append(commonNames, user.Username)
+			}
+		}
+		err = instance.validateCertificateUsers(commonNames)
+		if err != nil {
+			return nil, fmt.Errorf("valkey instance %s: %w", instance.Name, err)
+		}
+	}
+	return instances, nil
+}
+
+// newDefaultInstanceSpec returns an instance spec with every optional setting populated with its default.
+func newDefaultInstanceSpec() *InstanceSpec {
+	return &InstanceSpec{
+		Backend:     BackendValkey,
+		Mode:        ModeReplication,
+		Replicas:    3,
+		Sharding:    newDefaultShardingConfig(),
+		Persistence: newDefaultPersistenceConfig(),
+		Sizing:      newDefaultSizingConfig(),
+		Server:      newDefaultServerConfig(),
+		Placement:   newDefaultPlacementConfig(),
+		Sentinel:    newDefaultSentinelConfig(),
+		Metrics:     newDefaultMetricsConfig(),
+		Backup:      newDefaultBackupConfig(),
+		Restore:     &RestoreConfig{},
+		TLS:         newDefaultTLSConfig(),
+		Functions:   newDefaultFunctionsConfig(),
+		CertReload:  newDefaultCertReloadConfig(),
+		Upgrade:     newDefaultUpgradeConfig(),
+		Expose:      newDefaultExposureConfig(),
+		Benchmark:   newDefaultBenchmarkConfig(),
+		Proxy:       newDefaultProxyConfig(),
+	}
+}
+
+// namespaceName returns the namespace the instance is deployed to.
+func (spec *InstanceSpec) namespaceName(name string) string {
+	if spec.Namespace == "" {
+		return name
+	}
+	return spec.Namespace
+}
+
+// validate checks the instance spec before any resource is registered.
+func (spec *InstanceSpec) validate() error {
+	err := validateBackend(spec.Backend)
+	if err != nil {
+		return err
+	}
+	err = validateMode(spec.Mode)
+	if err != nil {
+		return err
+	}
+	if spec.Mode == ModeCluster {
+		err = spec.Sharding.validate()
+		if err != nil {
+			return err
+		}
+	}
+	if spec.Replicas < 1 {
+		return fmt.Errorf("%w: at least one replica is required", ErrInvalidInstanceConfig)
+	}
+	if spec.Mode == ModeReplication {
+		err = spec.Sentinel.validate(spec.Replicas)
+		if err != nil {
+			return err
+		}
+	}
+	err = spec.Placement.validate()
+	if err != nil {
+		return err
+	}
+	err = spec.Persistence.validate()
+	if err != nil {
+		return err
+	}
+	err = spec.Sizing.validate()
+	if err != nil {
+		return err
+	}
+	err = spec.Server.validate()
+	if err != nil {
+		return err
+	}
+	err = spec.Metrics.validate()
+	if err != nil {
+		return err
+	}
+	for _, client := range spec.AllowedClients {
+		err = client.validate()
+		if err != nil {
+			return err
+		}
+	}
+	err = spec.TLS.validate()
+	if err != nil {
+		return err
+	}
+	err = spec.CertReload.validate()
+	if err != nil {
+		return err
+	}
+	err = spec.Expose.validate()
+	if err != nil {
+		return err
+	}
+	if spec.Expose.enabled() && spec.Mode == ModeCluster {
+		return fmt.Errorf("%w: cluster mode announces in-cluster addresses to clients", ErrInvalidExposureConfig)
+	}
+	err = spec.Benchmark.validate()
+	if err != nil {
+		return err
+	}
+	err = spec.validateProxy()
+	if err != nil {
+		return err
+	}
+	err = spec.Upgrade.validate()
+	if err != nil {
+		return err
+	}
+	err = spec.Functions.validate()
+	if err != nil {
+		return err
+	}
+	err = spec.validateBackup()
+	if err != nil {
+		return err
+	}
+	err = spec.validateModules()
+	if err != nil {
+		return err
+	}
+	return spec.validatePasswords()
+}
+
+// newInstanceDependencies returns the resources the pulumi.DependsOn options of [opts] list. Other options are
+// rejected, since they can't be applied to every resource of an instance alike.
+func newInstanceDependencies(opts []pulumi.ResourceOption) ([]pulumi.Resource, error) {
+	options, err := pulumi.NewResourceOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	deps := options.DependsOn
+	options.DependsOn = nil
+	if !reflect.DeepEqual(*options, pulumi.ResourceOptions{}) {
+		return nil, fmt.Errorf("%w: only pulumi.DependsOn options are supported", ErrInvalidInstanceConfig)
+	}
+	return deps, nil
+}
+
+// exportName returns the name of an instance's stack output. The instance name prefixes the output so that every
+// instance's outputs can coexist, e.g. `cacheConnection` and `queueConnection`.
+func exportName(name string, suffix string) string {
+	return name + suffix
+}
+
+// newValkeyPodSelectorLabels returns the labels the bitnami charts put on an instance's Valkey pods.
+func newValkeyPodSelectorLabels(name string, spec *InstanceSpec) pulumi.StringMap {
+	chart := chartName
+	if spec.Mode == ModeCluster {
+		chart = shardedChartName
+	}
+	return pulumi.StringMap{
+		"app.kubernetes.io/instance": pulumi.String(name),
+		"app.kubernetes.io/name":     pulumi.String(chart),
+	}
+}
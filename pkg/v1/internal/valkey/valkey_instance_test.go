@@ -0,0 +1,127 @@
+package valkey
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testImageDigest is a well-formed digest for the images the tests have to pin.
+var testImageDigest = "sha256:" + strings.Repeat("a", 64)
+
+// newTestInstanceSpec returns a default instance spec with the passwords it needs to validate.
+func newTestInstanceSpec() *InstanceSpec {
+	spec := newDefaultInstanceSpec()
+	spec.DefaultUserCredentials = "default-password"
+	spec.SentinelUserCredentials = "sentinel-password"
+	spec.ReplicaUserCredentials = "replica-password"
+	spec.OperatorUserCredentials = "operator-password"
+	return spec
+}
+
+func TestInstanceSpecValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(spec *InstanceSpec)
+		want   error
+	}{
+		{
+			name:   "defaults",
+			modify: func(*InstanceSpec) {},
+		},
+		{
+			name:   "unknown backend",
+			modify: func(spec *InstanceSpec) { spec.Backend = "keydb" },
+			want:   ErrUnknownBackend,
+		},
+		{
+			name:   "unknown mode",
+			modify: func(spec *InstanceSpec) { spec.Mode = "standalone" },
+			want:   ErrUnknownMode,
+		},
+		{
+			name:   "no replicas",
+			modify: func(spec *InstanceSpec) { spec.Replicas = 0 },
+			want:   ErrInvalidInstanceConfig,
+		},
+		{
+			name:   "two replicas can't keep a sentinel majority",
+			modify: func(spec *InstanceSpec) { spec.Replicas = 2 },
+			want:   ErrInvalidSentinelConfig,
+		},
+		{
+			name: "cluster mode",
+			modify: func(spec *InstanceSpec) {
+				spec.Mode = ModeCluster
+				spec.Sharding.ImageDigest = testImageDigest
+			},
+		},
+		{
+			name:   "cluster mode with an unpinned image",
+			modify: func(spec *InstanceSpec) { spec.Mode = ModeCluster },
+			want:   ErrUnpinnedImage,
+		},
+		{
+			name: "cluster mode with too few shards",
+			modify: func(spec *InstanceSpec) {
+				spec.Mode = ModeCluster
+				spec.Sharding.Shards = 2
+				spec.Sharding.ImageDigest = testImageDigest
+			},
+			want: ErrInvalidShardingConfig,
+		},
+		{
+			name: "cluster mode doesn't check sentinel passwords",
+			modify: func(spec *InstanceSpec) {
+				spec.Mode = ModeCluster
+				spec.Sharding.ImageDigest = testImageDigest
+				spec.SentinelUserCredentials = ""
+				spec.ReplicaUserCredentials = ""
+			},
+		},
+		{
+			name: "cluster mode can't be exposed",
+			modify: func(spec *InstanceSpec) {
+				spec.Mode = ModeCluster
+				spec.Sharding.ImageDigest = testImageDigest
+				spec.Expose = &ExposureConfig{Type: ExposureLoadBalancer, SourceRanges: []string{"10.0.0.0/8"}}
+			},
+			want: ErrInvalidExposureConfig,
+		},
+		{
+			name:   "missing default password",
+			modify: func(spec *InstanceSpec) { spec.DefaultUserCredentials = "" },
+			want:   ErrInvalidPassword,
+		},
+		{
+			name:   "allowed client without a namespace",
+			modify: func(spec *InstanceSpec) { spec.AllowedClients = []*AllowedClient{{}} },
+			want:   ErrInvalidAllowedClient,
+		},
+		{
+			name:   "certificate users without client certificates",
+			modify: func(spec *InstanceSpec) { spec.TLS = &TLSConfig{CertificateUsers: true} },
+			want:   ErrInvalidTLSConfig,
+		},
+		{
+			name:   "negative upgrade lag",
+			modify: func(spec *InstanceSpec) { spec.Upgrade.MaxOffsetLag = -1 },
+			want:   ErrInvalidUpgradeConfig,
+		},
+		{
+			name:   "unknown module",
+			modify: func(spec *InstanceSpec) { spec.Modules = []Module{"graph"}; spec.ModuleImageDigest = testImageDigest },
+			want:   ErrInvalidModule,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := newTestInstanceSpec()
+			tt.modify(spec)
+			err := spec.validate()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package valkey
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ErrInvalidMetricsConfig is returned when metrics are enabled without the credentials of the metrics user.
+var ErrInvalidMetricsConfig = fmt.Errorf("invalid metrics config")
+
+const (
+	metricsComponent = "metrics"
+	metricsPort      = 9121
+	metricsPortName  = "http-metrics"
+	metricsSecretKey = "metrics-password"
+	// metricsUsername is the least-privilege ACL user the exporter authenticates as.
+	metricsUsername = "metrics-user"
+	// valkeyRunAsUser is the UID of the bitnami images, which is the only UID allowed to read the mounted certificates.
+	valkeyRunAsUser = 1001
+)
+
+// MetricsConfig enables the chart's Prometheus exporter sidecar of an instance along with its ServiceMonitor and
+// PrometheusRule. Both custom resources require the Prometheus operator CRDs to be installed.
+type MetricsConfig struct {
+	Enabled bool `json:"enabled"`
+	// Password of the metrics ACL user.
+	Password string `json:"password"`
+	// MonitoringNamespace is the namespace Prometheus scrapes from.
+	MonitoringNamespace string `json:"monitoringNamespace"`
+	ScrapeInterval      string `json:"scrapeInterval"`
+	// Labels are added to the ServiceMonitor and PrometheusRule so that the Prometheus instance selects them.
+	Labels map[string]string `json:"labels"`
+}
+
+// newDefaultMetricsConfig returns the disabled metrics config used when the stack config doesn't enable metrics.
+func newDefaultMetricsConfig() *MetricsConfig {
+	return &MetricsConfig{
+		MonitoringNamespace: "monitoring",
+		ScrapeInterval:      "30s",
+	}
+}
+
+// validate checks that enabled metrics come with a password for the metrics user.
+func (m *MetricsConfig) validate() error {
+	if m.Enabled && m.Password == "" {
+		return fmt.Errorf("%w: the metrics user requires a password", ErrInvalidMetricsConfig)
+	}
+	return nil
+}
+
+// MetricsUsername exposes [metricsUsername] to the ACL template.
+func (m *MetricsConfig) MetricsUsername() string {
+	return metricsUsername
+}
+
+// newValkeyMetricsValues returns the `metrics` values of the bitnami charts. The chart's redis_exporter sidecar
+// scrapes the local Valkey server as [metricsUsername], which is only allowed to run INFO, CLIENT LIST, CONFIG GET,
+// and PING, instead of the default user the chart authenticates as. In [ModeReplication] the ServiceMonitor also
+// scrapes the sentinel next to every Valkey pod through the exporter's multi-target endpoint, labelling its series
+// with `app="sentinel"`.
+func newValkeyMetricsValues(name string, spec *InstanceSpec) pulumi.Map {
+	endpoints := pulumi.Array{}
+	if spec.Mode == ModeReplication {
+		endpoints = append(endpoints, pulumi.Map{
+			"port":     pulumi.String(metricsPortName),
+			"path":     pulumi.String("/scrape"),
+			"interval": pulumi.String(spec.Metrics.ScrapeInterval),
+			"params": pulumi.Map{
+				"target": pulumi.StringArray{
+					pulumi.String(fmt.Sprintf("rediss://localhost:%d", sentinelPort)),
+				},
+			},
+			"metricRelabelings": pulumi.Array{
+				pulumi.Map{
+					"targetLabel": pulumi.String("app"),
+					"replacement": pulumi.String("sentinel"),
+				},
+			},
+		})
+	}
+
+	// The valkey chart calls the extra ServiceMonitor labels `additionalLabels`, the valkey-cluster chart `labels`.
+	labelsKey := "additionalLabels"
+	if spec.Mode == ModeCluster {
+		labelsKey = "labels"
+	}
+	return pulumi.Map{
+		"enabled": pulumi.Bool(true),
+		// Environment variables defined later override the chart's REDIS_USER and REDIS_PASSWORD.
+		"extraEnvVars": pulumi.Array{
+			pulumi.Map{
+				"name":  pulumi.String("REDIS_USER"),
+				"value": pulumi.String(metricsUsername),
+			},
+			pulumi.Map{
+				"name": pulumi.String("REDIS_PASSWORD"),
+				"valueFrom": pulumi.Map{
+					"secretKeyRef": pulumi.Map{
+						"name": pulumi.String(aclSecretName(name)),
+						"key":  pulumi.String(metricsSecretKey),
+					},
+				},
+			},
+		},
+		"resources": pulumi.Map{
+			"limits": pulumi.Map{
+				"cpu":    pulumi.String("100m"),
+				"memory": pulumi.String("128Mi"),
+			},
+			"requests": pulumi.Map{
+				"cpu":    pulumi.String("20m"),
+				"memory": pulumi.String("32Mi"),
+			},
+		},
+		"serviceMonitor": pulumi.Map{
+			"enabled":             pulumi.Bool(true),
+			"port":                pulumi.String(metricsPortName),
+			"interval":            pulumi.String(spec.Metrics.ScrapeInterval),
+			labelsKey:             pulumi.ToStringMap(spec.Metrics.Labels),
+			"additionalEndpoints": endpoints,
+		},
+	}
+}
+
+// newValkeySentinelMetricsUser returns the sentinel configuration adding [metricsUsername] to every sentinel, so that
+// the exporter can scrape the sentinels with the credentials it scrapes Valkey with. Sentinel doesn't load the ACL
+// file, so the user is declared with the SHA-256 hash of its password instead of the password itself.
+func newValkeySentinelMetricsUser(spec *InstanceSpec) string {
+	hash := sha256.Sum256([]byte(spec.Metrics.Password))
+	return fmt.Sprintf(
+		"user %s on #%x -@all +ping +info +client|setname +sentinel|masters +sentinel|master +sentinel|sentinels "+
+			"+sentinel|replicas +sentinel|slaves +sentinel|ckquorum",
+		metricsUsername,
+		hash,
+	)
+}
+
+// deployValkeyMetrics deploys the PrometheusRule alerting on the series the chart's ServiceMonitor scrapes.
+func deployValkeyMetrics(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	rule, err := apiextensions.NewCustomResource(
+		ctx,
+		fmt.Sprintf("%s-prometheus-rule", name),
+		newValkeyPrometheusRuleArgs(name, namespace, spec),
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{rule}, nil
+}
+
+// newValkeyPrometheusRuleArgs returns the PrometheusRule alerting on memory pressure, evictions, replication lag,
+// primary changes, rejected connections, and ACL authentication failures of the instance.
+//
+// A primary change is detected from the address the sentinels report for the primary. The bitnami chart announces
+// hostnames, so the address only changes on a failover and not when a pod restarts with a new IP.
+func newValkeyPrometheusRuleArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+) *apiextensions.CustomResourceArgs {
+	series := fmt.Sprintf(`namespace="%s",service="%s"`, spec.namespaceName(name), metricsServiceName(name))
+	selector := series + `,app!="sentinel"`
+	sentinelSelector := series + `,app="sentinel"`
+	rule := func(alert string, expr string, duration string, severity string, summary string) kubernetes.UntypedArgs {
+		return kubernetes.UntypedArgs{
+			"alert": pulumi.String(alert),
+			"expr":  pulumi.String(expr),
+			"for":   pulumi.String(duration),
+			"labels": pulumi.StringMap{
+				"severity": pulumi.String(severity),
+			},
+			"annotations": pulumi.StringMap{
+				"summary": pulumi.String(fmt.Sprintf("Valkey instance %s: %s", name, summary)),
+			},
+		}
+	}
+
+	return &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("monitoring.coreos.com/v1"),
+		Kind:       pulumi.String("PrometheusRule"),
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String(metricsServiceName(name)),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    newValkeyMetricsLabels(name, spec.Metrics.Labels),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": kubernetes.UntypedArgs{
+				"groups": pulumi.Array{
+					kubernetes.UntypedArgs{
+						"name": pulumi.String(fmt.Sprintf("valkey-%s", name)),
+						"rules": pulumi.Array{
+							rule(
+								"ValkeyMemoryPressure",
+								fmt.Sprintf(
+									"redis_memory_used_bytes{%[1]s} / redis_memory_max_bytes{%[1]s} > 0.9 "+
+										"and redis_memory_max_bytes{%[1]s} > 0",
+									selector,
+								),
+								"5m",
+								"warning",
+								"memory usage is above 90% of maxmemory",
+							),
+							rule(
+								"ValkeyKeysEvicted",
+								fmt.Sprintf("increase(redis_evicted_keys_total{%s}[5m]) > 0", selector),
+								"0m",
+								"warning",
+								"keys are being evicted",
+							),
+							rule(
+								"ValkeyReplicationLag",
+								fmt.Sprintf("redis_connected_slave_lag_seconds{%s} > 10", selector),
+								"5m",
+								"warning",
+								"a replica lags more than 10 seconds behind its primary",
+							),
+							rule(
+								"ValkeyPrimaryChanged",
+								fmt.Sprintf(
+									`count by (master_name) (count by (master_name, master_address) `+
+										`(max_over_time(redis_sentinel_master_status{%s}[15m]))) > 1`,
+									sentinelSelector,
+								),
+								"0m",
+								"info",
+								"sentinel promoted a new primary in the last 15 minutes",
+							),
+							rule(
+								"ValkeyRejectedConnections",
+								fmt.Sprintf("increase(redis_rejected_connections_total{%s}[5m]) > 0", selector),
+								"0m",
+								"warning",
+								"connections are rejected because maxclients was reached",
+							),
+							rule(
+								"ValkeyACLAuthFailures",
+								fmt.Sprintf("increase(redis_acl_access_denied_auth_total{%s}[5m]) > 5", selector),
+								"0m",
+								"warning",
+								"clients are failing ACL authentication",
+							),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// newValkeyMetricsLabels returns the labels of the metrics resources, merged with any extra labels.
+func newValkeyMetricsLabels(name string, extra map[string]string) pulumi.StringMap {
+	labels := newValkeyInstanceLabels(name)
+	labels["app.kubernetes.io/component"] = pulumi.String(metricsComponent)
+	for key, value := range extra {
+		labels[key] = pulumi.String(value)
+	}
+	return labels
+}
+
+// metricsServiceName returns the name of the Service the bitnami charts expose an instance's exporters with.
+func metricsServiceName(name string) string {
+	return fmt.Sprintf("%s-metrics", name)
+}
@@ -0,0 +1,69 @@
+package valkey
+
+import (
+	"fmt"
+)
+
+// ErrInvalidShardingConfig is returned when the sharding settings of a Valkey Cluster are out of range.
+var ErrInvalidShardingConfig = fmt.Errorf("invalid sharding config")
+
+// ErrUnknownMode is returned when a Valkey instance is configured with an unsupported mode.
+var ErrUnknownMode = fmt.Errorf("unknown valkey mode")
+
+// minShards is the smallest number of primaries Valkey Cluster accepts.
+const minShards = 3
+
+// Mode selects how a Valkey instance is topologically deployed.
+type Mode string
+
+const (
+	// ModeReplication runs a single primary with replicas, supervised by sentinel.
+	ModeReplication Mode = "replication"
+	// ModeCluster runs a sharded Valkey Cluster where every shard has its own primary and replicas.
+	// SEE: https://valkey.io/topics/cluster-tutorial/
+	ModeCluster Mode = "cluster"
+)
+
+// ShardingConfig describes the shape of a Valkey Cluster deployed in [ModeCluster].
+type ShardingConfig struct {
+	Shards           int `json:"shards"`
+	ReplicasPerShard int `json:"replicasPerShard"`
+	// ImageDigest pins the [shardedImageRepository] image the `valkey-cluster` chart runs, like [imageDigest] pins the
+	// image of [ModeReplication]. It is required, since the chart would otherwise pull a mutable tag.
+	ImageDigest string `json:"imageDigest"`
+}
+
+// newDefaultShardingConfig returns the smallest highly available Valkey Cluster: three shards with one replica each.
+func newDefaultShardingConfig() *ShardingConfig {
+	return &ShardingConfig{
+		Shards:           minShards,
+		ReplicasPerShard: 1,
+	}
+}
+
+// Nodes returns the total number of Valkey pods across all shards.
+func (s *ShardingConfig) Nodes() int {
+	return s.Shards * (1 + s.ReplicasPerShard)
+}
+
+// validate checks that the cluster has enough shards to form a cluster, a non-negative replica count, and a pinned
+// image.
+func (s *ShardingConfig) validate() error {
+	if s.Shards < minShards {
+		return fmt.Errorf("%w: at least %d shards are required, got %d", ErrInvalidShardingConfig, minShards, s.Shards)
+	}
+	if s.ReplicasPerShard < 0 {
+		return fmt.Errorf("%w: replicas per shard must not be negative", ErrInvalidShardingConfig)
+	}
+	return validateImageDigest(shardedImageRepository, s.ImageDigest)
+}
+
+// validateMode rejects modes other than [ModeReplication] and [ModeCluster].
+func validateMode(mode Mode) error {
+	switch mode {
+	case ModeReplication, ModeCluster:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownMode, mode)
+	}
+}
@@ -0,0 +1,224 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidModule is returned when an instance enables an unknown module, or a user is granted the commands of a
+// module the instance doesn't load.
+var ErrInvalidModule = fmt.Errorf("invalid module")
+
+// Module is a Valkey module an instance can load.
+type Module string
+
+const (
+	// ModuleJSON adds the `JSON.*` commands of valkey-json.
+	ModuleJSON Module = "json"
+	// ModuleBloom adds the `BF.*` commands of valkey-bloom.
+	ModuleBloom Module = "bloom"
+	// ModuleSearch adds the `FT.*` commands of valkey-search.
+	ModuleSearch Module = "search"
+)
+
+const (
+	// moduleImage is the module-enabled Valkey image the module libraries are copied from. It is built on the same
+	// Debian release as the bitnami image, so the libraries load into the bitnami Valkey server. The modules are built
+	// against the module API of the bundled server, which [moduleVersionCheckScript] compares with the server's own
+	// version before Valkey starts. The tag only documents the version, since the image is pinned by the digest in
+	// [InstanceSpec.ModuleImageDigest].
+	moduleImage         = "docker.io/valkey/valkey-bundle:8.1.1"
+	moduleImagePath     = "/usr/lib/valkey"
+	moduleMountPath     = "/opt/bitnami/valkey/modules"
+	moduleVolumeName    = "valkey-modules"
+	moduleInitCopyName  = "modules"
+	moduleInitCheckName = "modules-check"
+	// moduleVersionFile records the major and minor version of the server the modules were built with.
+	moduleVersionFile = ".valkey-version"
+)
+
+// moduleVersionCommand prints the major and minor version of the valkey-server on the PATH, e.g. `8.1`.
+const moduleVersionCommand = `valkey-server --version | sed -n 's/.* v=\([0-9]*\.[0-9]*\).*/\1/p'`
+
+// moduleCopyScript copies the module libraries into the module volume along with the version of the server they were
+// built with.
+const moduleCopyScript = `set -e
+cp %[1]s %[2]s/
+` + moduleVersionCommand + ` > %[2]s/%[3]s
+`
+
+// moduleVersionCheckScript fails the pod when the modules were built for another major or minor version of Valkey than
+// the server of the pod, whose module API they might not match.
+const moduleVersionCheckScript = `set -e
+modules="$(cat %[1]s/%[2]s)"
+server="$(` + moduleVersionCommand + `)"
+if [ -z "${server}" ] || [ "${modules}" != "${server}" ]; then
+  echo "the modules were built for Valkey ${modules} but the server is Valkey ${server}" >&2
+  exit 1
+fi
+`
+
+// moduleDefinition describes where a module's library lives and how its commands are named.
+type moduleDefinition struct {
+	// library is the file name of the module in [moduleImagePath].
+	library string
+	// aclCategory is the ACL category the module registers its commands in.
+	aclCategory string
+	// commandPrefix prefixes every command of the module.
+	commandPrefix string
+}
+
+// moduleDefinitions lists the modules an instance can load. [moduleOrder] fixes the order they are loaded in.
+var moduleDefinitions = map[Module]moduleDefinition{
+	ModuleJSON:   {library: "libjson.so", aclCategory: "json", commandPrefix: "json."},
+	ModuleBloom:  {library: "libvalkey_bloom.so", aclCategory: "bloom", commandPrefix: "bf."},
+	ModuleSearch: {library: "libsearch.so", aclCategory: "search", commandPrefix: "ft."},
+}
+
+var moduleOrder = []Module{ModuleJSON, ModuleBloom, ModuleSearch}
+
+// validateModules checks that the modules are known and listed once, that their image is pinned, and that users are
+// only granted the commands of loaded modules.
+func (spec *InstanceSpec) validateModules() error {
+	if len(spec.Modules) > 0 {
+		err := validateImageDigest(moduleImage, spec.ModuleImageDigest)
+		if err != nil {
+			return err
+		}
+	}
+	for i, module := range spec.Modules {
+		if _, ok := moduleDefinitions[module]; !ok {
+			return fmt.Errorf("%w: unknown module %q", ErrInvalidModule, module)
+		}
+		if slices.Contains(spec.Modules[:i], module) {
+			return fmt.Errorf("%w: module %s is listed twice", ErrInvalidModule, module)
+		}
+	}
+	for _, user := range spec.Users {
+		for _, rule := range user.EnabledCommands {
+			module, ok := ruleModule(rule)
+			if ok && !slices.Contains(spec.Modules, module) {
+				return fmt.Errorf(
+					"%w: user %s is granted %s but module %s isn't loaded",
+					ErrInvalidModule,
+					user.Username,
+					rule,
+					module,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// ModulePaths returns the paths of the module libraries loaded with `loadmodule`, in a fixed order.
+func (spec *InstanceSpec) ModulePaths() []string {
+	var paths []string
+	for _, module := range moduleOrder {
+		if slices.Contains(spec.Modules, module) {
+			paths = append(paths, fmt.Sprintf("%s/%s", moduleMountPath, moduleDefinitions[module].library))
+		}
+	}
+	return paths
+}
+
+// ACLRules returns the ACL rules of the user as written to the ACL file. Valkey doesn't match command names against
+// patterns, so a grant of every command of a module, like `+JSON.*`, is rewritten to the module's ACL category.
+func (u *valkeyUser) ACLRules() []string {
+	rules := make([]string, 0, len(u.EnabledCommands))
+	for _, rule := range u.EnabledCommands {
+		rules = append(rules, moduleWildcardToCategory(rule))
+	}
+	return rules
+}
+
+// moduleWildcardToCategory rewrites a `+PREFIX.*` or `-PREFIX.*` rule to the category of the module owning the
+// prefix, and returns any other rule unchanged.
+func moduleWildcardToCategory(rule string) string {
+	if len(rule) < 2 || (rule[0] != '+' && rule[0] != '-') || !strings.HasSuffix(rule, "*") {
+		return rule
+	}
+	prefix := strings.ToLower(strings.TrimSuffix(rule[1:], "*"))
+	for _, module := range moduleOrder {
+		if prefix == moduleDefinitions[module].commandPrefix {
+			return fmt.Sprintf("%c@%s", rule[0], moduleDefinitions[module].aclCategory)
+		}
+	}
+	return rule
+}
+
+// ruleModule returns the module whose commands or category a command rule refers to.
+func ruleModule(rule string) (Module, bool) {
+	if !strings.HasPrefix(rule, "+") && !strings.HasPrefix(rule, "-") {
+		return "", false
+	}
+	rule = strings.ToLower(rule[1:])
+	for _, module := range moduleOrder {
+		definition := moduleDefinitions[module]
+		if rule == "@"+definition.aclCategory || strings.HasPrefix(rule, definition.commandPrefix) {
+			return module, true
+		}
+	}
+	return "", false
+}
+
+// newValkeyModuleInitContainers returns the chart values of the init containers copying the module libraries out of
+// [moduleImage] into the volume the Valkey server loads them from, and checking that they match the server version.
+// The check runs in the server image of the instance's mode.
+func newValkeyModuleInitContainers(spec *InstanceSpec) pulumi.Array {
+	var libraries []string
+	for _, module := range moduleOrder {
+		if slices.Contains(spec.Modules, module) {
+			libraries = append(libraries, fmt.Sprintf("%s/%s", moduleImagePath, moduleDefinitions[module].library))
+		}
+	}
+	mount := pulumi.Map{
+		"name":      pulumi.String(moduleVolumeName),
+		"mountPath": pulumi.String(moduleMountPath),
+	}
+	containers := pulumi.Array{
+		pulumi.Map{
+			"name":    pulumi.String(moduleInitCopyName),
+			"image":   pulumi.String(fmt.Sprintf("%s@%s", moduleImage, spec.ModuleImageDigest)),
+			"command": pulumi.StringArray{pulumi.String("/bin/sh"), pulumi.String("-c")},
+			"args": pulumi.StringArray{
+				pulumi.String(
+					fmt.Sprintf(moduleCopyScript, strings.Join(libraries, " "), moduleMountPath, moduleVersionFile),
+				),
+			},
+			"volumeMounts": pulumi.Array{mount},
+		},
+	}
+	serverImage := fmt.Sprintf("%s@%s", imageRepository, imageDigest)
+	if spec.Mode == ModeCluster {
+		serverImage = fmt.Sprintf("%s@%s", shardedImageRepository, spec.Sharding.ImageDigest)
+	}
+	return append(containers, pulumi.Map{
+		"name":    pulumi.String(moduleInitCheckName),
+		"image":   pulumi.String(serverImage),
+		"command": pulumi.StringArray{pulumi.String("/bin/bash"), pulumi.String("-c")},
+		"args": pulumi.StringArray{
+			pulumi.String(fmt.Sprintf(moduleVersionCheckScript, moduleMountPath, moduleVersionFile)),
+		},
+		"volumeMounts": pulumi.Array{mount},
+	})
+}
+
+// newValkeyModuleVolume returns the volume holding the module libraries.
+func newValkeyModuleVolume() pulumi.Map {
+	return pulumi.Map{
+		"name":     pulumi.String(moduleVolumeName),
+		"emptyDir": pulumi.Map{},
+	}
+}
+
+// newValkeyModuleVolumeMount returns the read-only mount of the module volume at [moduleMountPath].
+func newValkeyModuleVolumeMount() pulumi.Map {
+	return pulumi.Map{
+		"name":      pulumi.String(moduleVolumeName),
+		"mountPath": pulumi.String(moduleMountPath),
+		"readOnly":  pulumi.Bool(true),
+	}
+}
@@ -0,0 +1,68 @@
+package valkey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInstanceSpecValidateModules(t *testing.T) {
+	tests := []struct {
+		name     string
+		modules  []Module
+		digest   string
+		commands []string
+		want     error
+	}{
+		{
+			name: "no modules",
+		},
+		{
+			name:     "granted commands of loaded modules",
+			modules:  []Module{ModuleJSON, ModuleBloom},
+			digest:   testImageDigest,
+			commands: []string{"+json.get", "+@bloom", "+get"},
+		},
+		{
+			name:    "unpinned image",
+			modules: []Module{ModuleJSON},
+			digest:  "8.1.1",
+			want:    ErrUnpinnedImage,
+		},
+		{
+			name:    "unknown module",
+			modules: []Module{"graph"},
+			digest:  testImageDigest,
+			want:    ErrInvalidModule,
+		},
+		{
+			name:    "module listed twice",
+			modules: []Module{ModuleSearch, ModuleSearch},
+			digest:  testImageDigest,
+			want:    ErrInvalidModule,
+		},
+		{
+			name:     "granted commands of a module that isn't loaded",
+			modules:  []Module{ModuleJSON},
+			digest:   testImageDigest,
+			commands: []string{"+ft.search"},
+			want:     ErrInvalidModule,
+		},
+		{
+			name:     "granted category of a module that isn't loaded",
+			commands: []string{"+@json"},
+			want:     ErrInvalidModule,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := newTestInstanceSpec()
+			spec.Modules = tt.modules
+			spec.ModuleImageDigest = tt.digest
+			spec.Users = []*valkeyUser{{Username: "app", Password: "app-password", EnabledCommands: tt.commands}}
+			err := spec.validateModules()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validateModules() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -7,20 +7,18 @@ import (
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
-const (
-	clusterNamespace = "valkey"
-)
-
-// deployValkeyClusterNamespace creates the `valkey` namespace.
+// deployValkeyClusterNamespace creates the namespace of a Valkey instance.
 func deployValkeyClusterNamespace(
 	ctx *pulumi.Context,
+	name string,
+	namespaceName string,
 	provider *kubernetes.Provider,
 	deps []pulumi.Resource,
 ) (*corev1.Namespace, error) {
-	args := newValkeyClusterNamespaceArgs()
+	args := newValkeyClusterNamespaceArgs(name, namespaceName)
 	ns, err := corev1.NewNamespace(
 		ctx,
-		clusterNamespace,
+		namespaceName,
 		args,
 		pulumi.Provider(provider),
 		pulumi.DependsOn(deps),
@@ -31,14 +29,20 @@ func deployValkeyClusterNamespace(
 	return ns, nil
 }
 
-// newValkeyClusterNamespaceArgs returns the corev1.NamespaceArgs used to create the `valkey` namespace.
-func newValkeyClusterNamespaceArgs() *corev1.NamespaceArgs {
+// newValkeyClusterNamespaceArgs returns the corev1.NamespaceArgs used to create the namespace of a Valkey instance.
+func newValkeyClusterNamespaceArgs(name string, namespaceName string) *corev1.NamespaceArgs {
 	return &corev1.NamespaceArgs{
 		Metadata: &metav1.ObjectMetaArgs{
-			Name: pulumi.String(clusterNamespace),
-			Labels: pulumi.StringMap{
-				"app": pulumi.String(clusterAppLabel),
-			},
+			Name:   pulumi.String(namespaceName),
+			Labels: newValkeyInstanceLabels(name),
 		},
 	}
 }
+
+// newValkeyInstanceLabels returns the labels shared by the resources of a Valkey instance.
+func newValkeyInstanceLabels(name string) pulumi.StringMap {
+	return pulumi.StringMap{
+		"app":                        pulumi.String(clusterAppLabel),
+		"app.kubernetes.io/instance": pulumi.String(name),
+	}
+}
@@ -0,0 +1,240 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ErrInvalidAllowedClient is returned when an allowed client doesn't name the namespace it connects from.
+var ErrInvalidAllowedClient = fmt.Errorf("invalid allowed client")
+
+const (
+	clusterBusPort = 16379
+	// namespaceNameLabel is set on every namespace by the API server.
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+)
+
+// AllowedClient selects the pods that may connect to an instance. Clients are always selected by namespace and
+// optionally narrowed down to the pods carrying PodLabels.
+type AllowedClient struct {
+	Namespace string            `json:"namespace"`
+	PodLabels map[string]string `json:"podLabels"`
+}
+
+// validate checks that the client names its namespace.
+func (c *AllowedClient) validate() error {
+	if c.Namespace == "" {
+		return fmt.Errorf("%w: a namespace is required", ErrInvalidAllowedClient)
+	}
+	return nil
+}
+
+// deployValkeyNetworkPolicies denies all ingress into the instance's namespace, then allows replication and sentinel
+// traffic between the Valkey pods, scraping from the monitoring namespace, and connections from the allowed clients to
+// the instance and its proxy.
+func deployValkeyNetworkPolicies(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	policies := map[string]*networkingv1.NetworkPolicySpecArgs{
+		"default-deny-ingress": newValkeyDefaultDenyIngressPolicySpec(),
+		"allow-peers":          newValkeyAllowPeersPolicySpec(name, spec),
+	}
+	if spec.Metrics.Enabled {
+		policies["allow-metrics"] = newValkeyAllowMetricsPolicySpec(name, spec)
+	}
+	if len(spec.AllowedClients) > 0 {
+		policies["allow-clients"] = newValkeyAllowClientsPolicySpec(name, spec)
+	}
+	if spec.Backup.LocalMinio {
+		policies["allow-minio"] = newValkeyAllowMinioPolicySpec(name)
+	}
+	if spec.Expose.enabled() {
+		policies["allow-external"] = newValkeyAllowExternalPolicySpec(name, spec)
+	}
+	if spec.Proxy.Enabled && len(spec.AllowedClients) > 0 {
+		policies["allow-proxy-clients"] = newValkeyAllowProxyClientsPolicySpec(name, spec)
+	}
+
+	var resources []pulumi.Resource
+	suffixes := []string{
+		"default-deny-ingress",
+		"allow-peers",
+		"allow-metrics",
+		"allow-clients",
+		"allow-minio",
+		"allow-external",
+		"allow-proxy-clients",
+	}
+	for _, suffix := range suffixes {
+		policySpec, ok := policies[suffix]
+		if !ok {
+			continue
+		}
+		policyName := fmt.Sprintf("%s-%s", name, suffix)
+		policy, err := networkingv1.NewNetworkPolicy(
+			ctx,
+			policyName,
+			&networkingv1.NetworkPolicyArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Name:      pulumi.String(policyName),
+					Namespace: namespace.Metadata.Name(),
+					Labels:    newValkeyInstanceLabels(name),
+				},
+				Spec: policySpec,
+			},
+			pulumi.Provider(provider),
+			pulumi.DependsOn(deps),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, policy)
+	}
+	return resources, nil
+}
+
+// newValkeyDefaultDenyIngressPolicySpec selects every pod in the namespace without allowing any ingress.
+func newValkeyDefaultDenyIngressPolicySpec() *networkingv1.NetworkPolicySpecArgs {
+	return &networkingv1.NetworkPolicySpecArgs{
+		PodSelector: &metav1.LabelSelectorArgs{},
+		PolicyTypes: pulumi.StringArray{pulumi.String("Ingress")},
+	}
+}
+
+// newValkeyAllowPeersPolicySpec allows the Valkey pods to replicate from each other, the sentinels to talk to each
+// other, and, in [ModeCluster], the cluster bus. The instance's tooling pods are let through as well.
+func newValkeyAllowPeersPolicySpec(name string, spec *InstanceSpec) *networkingv1.NetworkPolicySpecArgs {
+	ports := newNetworkPolicyPorts(valkeyPort, sentinelPort)
+	if spec.Mode == ModeCluster {
+		ports = newNetworkPolicyPorts(valkeyPort, clusterBusPort)
+	}
+	return &networkingv1.NetworkPolicySpecArgs{
+		PodSelector: &metav1.LabelSelectorArgs{
+			MatchLabels: newValkeyPodSelectorLabels(name, spec),
+		},
+		PolicyTypes: pulumi.StringArray{pulumi.String("Ingress")},
+		Ingress: networkingv1.NetworkPolicyIngressRuleArray{
+			&networkingv1.NetworkPolicyIngressRuleArgs{
+				From: networkingv1.NetworkPolicyPeerArray{
+					&networkingv1.NetworkPolicyPeerArgs{
+						PodSelector: &metav1.LabelSelectorArgs{
+							MatchLabels: newValkeyPodSelectorLabels(name, spec),
+						},
+					},
+					&networkingv1.NetworkPolicyPeerArgs{
+						PodSelector: &metav1.LabelSelectorArgs{
+							MatchLabels: pulumi.StringMap{
+								toolingLabel: pulumi.String(name),
+							},
+						},
+					},
+				},
+				Ports: ports,
+			},
+		},
+	}
+}
+
+// newValkeyAllowMetricsPolicySpec allows Prometheus in the monitoring namespace to scrape the exporter sidecars.
+func newValkeyAllowMetricsPolicySpec(name string, spec *InstanceSpec) *networkingv1.NetworkPolicySpecArgs {
+	return &networkingv1.NetworkPolicySpecArgs{
+		PodSelector: &metav1.LabelSelectorArgs{
+			MatchLabels: newValkeyPodSelectorLabels(name, spec),
+		},
+		PolicyTypes: pulumi.StringArray{pulumi.String("Ingress")},
+		Ingress: networkingv1.NetworkPolicyIngressRuleArray{
+			&networkingv1.NetworkPolicyIngressRuleArgs{
+				From: networkingv1.NetworkPolicyPeerArray{
+					&networkingv1.NetworkPolicyPeerArgs{
+						NamespaceSelector: newNamespaceNameSelector(spec.Metrics.MonitoringNamespace),
+					},
+				},
+				Ports: newNetworkPolicyPorts(metricsPort),
+			},
+		},
+	}
+}
+
+// newValkeyAllowClientsPolicySpec allows the instance's configured clients to reach Valkey and sentinel.
+func newValkeyAllowClientsPolicySpec(name string, spec *InstanceSpec) *networkingv1.NetworkPolicySpecArgs {
+	return &networkingv1.NetworkPolicySpecArgs{
+		PodSelector: &metav1.LabelSelectorArgs{
+			MatchLabels: newValkeyPodSelectorLabels(name, spec),
+		},
+		PolicyTypes: pulumi.StringArray{pulumi.String("Ingress")},
+		Ingress: networkingv1.NetworkPolicyIngressRuleArray{
+			&networkingv1.NetworkPolicyIngressRuleArgs{
+				From:  newAllowedClientPeers(spec),
+				Ports: newNetworkPolicyPorts(valkeyPort, sentinelPort),
+			},
+		},
+	}
+}
+
+// newAllowedClientPeers returns the NetworkPolicy peers matching the instance's configured clients.
+func newAllowedClientPeers(spec *InstanceSpec) networkingv1.NetworkPolicyPeerArray {
+	peers := networkingv1.NetworkPolicyPeerArray{}
+	for _, client := range spec.AllowedClients {
+		peer := &networkingv1.NetworkPolicyPeerArgs{
+			NamespaceSelector: newNamespaceNameSelector(client.Namespace),
+		}
+		if len(client.PodLabels) > 0 {
+			peer.PodSelector = &metav1.LabelSelectorArgs{
+				MatchLabels: pulumi.ToStringMap(client.PodLabels),
+			}
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// newValkeyAllowMinioPolicySpec allows every pod in the namespace, i.e. the backup CronJob and the restore init
+// containers, to reach the MinIO stand-in.
+func newValkeyAllowMinioPolicySpec(name string) *networkingv1.NetworkPolicySpecArgs {
+	return &networkingv1.NetworkPolicySpecArgs{
+		PodSelector: &metav1.LabelSelectorArgs{
+			MatchLabels: newValkeyMinioLabels(name),
+		},
+		PolicyTypes: pulumi.StringArray{pulumi.String("Ingress")},
+		Ingress: networkingv1.NetworkPolicyIngressRuleArray{
+			&networkingv1.NetworkPolicyIngressRuleArgs{
+				From: networkingv1.NetworkPolicyPeerArray{
+					&networkingv1.NetworkPolicyPeerArgs{
+						PodSelector: &metav1.LabelSelectorArgs{},
+					},
+				},
+				Ports: newNetworkPolicyPorts(minioPort),
+			},
+		},
+	}
+}
+
+// newNamespaceNameSelector selects a single namespace by its name.
+func newNamespaceNameSelector(namespaceName string) *metav1.LabelSelectorArgs {
+	return &metav1.LabelSelectorArgs{
+		MatchLabels: pulumi.StringMap{
+			namespaceNameLabel: pulumi.String(namespaceName),
+		},
+	}
+}
+
+// newNetworkPolicyPorts returns TCP NetworkPolicy ports for the supplied port numbers.
+func newNetworkPolicyPorts(ports ...int) networkingv1.NetworkPolicyPortArray {
+	policyPorts := networkingv1.NetworkPolicyPortArray{}
+	for _, port := range ports {
+		policyPorts = append(policyPorts, &networkingv1.NetworkPolicyPortArgs{
+			Port:     pulumi.Int(port),
+			Protocol: pulumi.String("TCP"),
+		})
+	}
+	return policyPorts
+}
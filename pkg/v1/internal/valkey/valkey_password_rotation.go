@@ -0,0 +1,99 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidRotationPhase is returned when a user is configured with an unknown password rotation phase.
+var ErrInvalidRotationPhase = fmt.Errorf("invalid password rotation phase")
+
+// ErrMissingNextPassword is returned when a user is mid-rotation but has no next password configured.
+var ErrMissingNextPassword = fmt.Errorf("password rotation requires a next password")
+
+// ErrInvalidPassword is returned when a password can't be written to a `>password` rule of the ACL file.
+var ErrInvalidPassword = fmt.Errorf("invalid password")
+
+const (
+	exportPasswordRotationPhases = "PasswordRotationPhases"
+)
+
+// passwordRotationPhase is a step in the zero-downtime password rotation workflow of a [valkeyUser]. Valkey accepts
+// several passwords per user, so a rotation is rolled out over consecutive updates:
+//
+//  1. [rotationPhaseAdd] adds the next password to the ACL alongside the current one.
+//  2. [rotationPhasePublish] publishes the next password to the consumer Secrets while both remain valid.
+//  3. [rotationPhaseRetire] removes the old password from the ACL.
+//
+// Once retired, the next password is the only one Valkey accepts. The operator then finishes the rotation in a single
+// update: the next password is moved to `password`, `nextPassword` is cleared, and the phase is set back to
+// [rotationPhaseStable]. Leaving the phase at [rotationPhaseStable] without swapping the passwords would reinstate the
+// retired password.
+type passwordRotationPhase string
+
+const (
+	rotationPhaseStable  passwordRotationPhase = "stable"
+	rotationPhaseAdd     passwordRotationPhase = "add"
+	rotationPhasePublish passwordRotationPhase = "publish"
+	rotationPhaseRetire  passwordRotationPhase = "retire"
+)
+
+// exportPasswordRotationPhasesOutput exports the current rotation phase of every user so that operators can tell
+// which step of a rotation was last rolled out.
+func exportPasswordRotationPhasesOutput(ctx *pulumi.Context, name string, users []*valkeyUser) {
+	phases := pulumi.StringMap{}
+	for _, user := range users {
+		phases[user.Username] = pulumi.String(user.phase())
+	}
+	ctx.Export(exportName(name, exportPasswordRotationPhases), phases)
+}
+
+// validatePassword checks that a password can be written to a `>password` rule of the ACL file. Valkey splits ACL
+// rules on whitespace, and a leading `>` or `<` would turn the rule into another password rule.
+func validatePassword(owner string, password string) error {
+	if password == "" {
+		return fmt.Errorf("%w: %s has no password", ErrInvalidPassword, owner)
+	}
+	if strings.HasPrefix(password, ">") || strings.HasPrefix(password, "<") {
+		return fmt.Errorf("%w: the password of %s can't start with > or <", ErrInvalidPassword, owner)
+	}
+	if strings.IndexFunc(password, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return fmt.Errorf("%w: the password of %s can't contain whitespace", ErrInvalidPassword, owner)
+	}
+	return nil
+}
+
+// validatePasswords checks every password the ACL file of the instance holds.
+func (spec *InstanceSpec) validatePasswords() error {
+	passwords := []struct {
+		owner    string
+		password string
+		enabled  bool
+	}{
+		{"the default user", spec.DefaultUserCredentials, true},
+		{"sentinel-user", spec.SentinelUserCredentials, spec.Mode != ModeCluster},
+		{"replica-user", spec.ReplicaUserCredentials, spec.Mode != ModeCluster},
+		{operatorUsername, spec.OperatorUserCredentials, true},
+		{metricsUsername, spec.Metrics.Password, spec.Metrics.Enabled},
+		{backupUsername, spec.Backup.Password, spec.Backup.Enabled},
+		{benchmarkUsername, spec.Benchmark.Password, spec.Benchmark.Enabled},
+	}
+	for _, entry := range passwords {
+		if !entry.enabled {
+			continue
+		}
+		err := validatePassword(entry.owner, entry.password)
+		if err != nil {
+			return err
+		}
+	}
+	for _, user := range spec.Users {
+		err := user.validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
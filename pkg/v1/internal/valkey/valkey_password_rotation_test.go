@@ -0,0 +1,107 @@
+package valkey
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     error
+	}{
+		{name: "valid", password: "s3cr3t-P@ssw0rd"},
+		{name: "inner angle brackets", password: "a>b<c"},
+		{name: "empty", password: "", want: ErrInvalidPassword},
+		{name: "leading greater than", password: ">secret", want: ErrInvalidPassword},
+		{name: "leading less than", password: "<secret", want: ErrInvalidPassword},
+		{name: "space", password: "two words", want: ErrInvalidPassword},
+		{name: "tab", password: "tab\tseparated", want: ErrInvalidPassword},
+		{name: "newline", password: "line\nbreak", want: ErrInvalidPassword},
+		{name: "control character", password: "bell\a", want: ErrInvalidPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePassword("user app", tt.password)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validatePassword(%q) = %v, want %v", tt.password, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValkeyUserValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		user *valkeyUser
+		want error
+	}{
+		{
+			name: "unset phase",
+			user: &valkeyUser{Username: "app", Password: "current"},
+		},
+		{
+			name: "stable phase",
+			user: &valkeyUser{Username: "app", Password: "current", RotationPhase: rotationPhaseStable},
+		},
+		{
+			name: "rotating",
+			user: &valkeyUser{Username: "app", Password: "current", NextPassword: "next", RotationPhase: rotationPhaseAdd},
+		},
+		{
+			name: "rotating without a next password",
+			user: &valkeyUser{Username: "app", Password: "current", RotationPhase: rotationPhasePublish},
+			want: ErrMissingNextPassword,
+		},
+		{
+			name: "rotating to an invalid password",
+			user: &valkeyUser{Username: "app", Password: "current", NextPassword: ">next", RotationPhase: rotationPhaseRetire},
+			want: ErrInvalidPassword,
+		},
+		{
+			name: "unknown phase",
+			user: &valkeyUser{Username: "app", Password: "current", NextPassword: "next", RotationPhase: "swap"},
+			want: ErrInvalidRotationPhase,
+		},
+		{
+			name: "invalid current password",
+			user: &valkeyUser{Username: "app", Password: "with space"},
+			want: ErrInvalidPassword,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.user.validate()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValkeyUserRotationPasswords(t *testing.T) {
+	tests := []struct {
+		phase         passwordRotationPhase
+		wantActive    []string
+		wantPublished string
+	}{
+		{phase: "", wantActive: []string{"current"}, wantPublished: "current"},
+		{phase: rotationPhaseStable, wantActive: []string{"current"}, wantPublished: "current"},
+		{phase: rotationPhaseAdd, wantActive: []string{"current", "next"}, wantPublished: "current"},
+		{phase: rotationPhasePublish, wantActive: []string{"current", "next"}, wantPublished: "next"},
+		{phase: rotationPhaseRetire, wantActive: []string{"next"}, wantPublished: "next"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.phase), func(t *testing.T) {
+			user := &valkeyUser{Username: "app", Password: "current", NextPassword: "next", RotationPhase: tt.phase}
+			if got := user.ActivePasswords(); !slices.Equal(got, tt.wantActive) {
+				t.Errorf("ActivePasswords() = %v, want %v", got, tt.wantActive)
+			}
+			if got := user.PublishedPassword(); got != tt.wantPublished {
+				t.Errorf("PublishedPassword() = %q, want %q", got, tt.wantPublished)
+			}
+		})
+	}
+}
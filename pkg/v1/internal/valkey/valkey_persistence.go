@@ -0,0 +1,120 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ErrInvalidPersistenceConfig is returned when the persistence settings of a Valkey instance are inconsistent.
+var ErrInvalidPersistenceConfig = fmt.Errorf("invalid persistence config")
+
+// PersistenceProfile selects which of Valkey's persistence mechanisms are enabled.
+// SEE: https://valkey.io/topics/persistence/
+type PersistenceProfile string
+
+const (
+	// PersistenceNone disables both RDB snapshots and the AOF and runs the pods without a PersistentVolumeClaim.
+	PersistenceNone PersistenceProfile = "none"
+	// PersistenceRDB enables periodic RDB snapshots only.
+	PersistenceRDB PersistenceProfile = "rdb"
+	// PersistenceAOF enables the append only file only.
+	PersistenceAOF PersistenceProfile = "aof"
+	// PersistenceRDBAndAOF enables both RDB snapshots and the append only file.
+	PersistenceRDBAndAOF PersistenceProfile = "rdb+aof"
+)
+
+// AppendFsync is the `appendfsync` policy used when the AOF is enabled.
+type AppendFsync string
+
+const (
+	AppendFsyncAlways   AppendFsync = "always"
+	AppendFsyncEverySec AppendFsync = "everysec"
+	AppendFsyncNo       AppendFsync = "no"
+)
+
+// PVCRetentionPolicy is the StatefulSet `persistentVolumeClaimRetentionPolicy` action applied to the data volumes.
+type PVCRetentionPolicy string
+
+const (
+	PVCRetain PVCRetentionPolicy = "Retain"
+	PVCDelete PVCRetentionPolicy = "Delete"
+)
+
+// PersistenceConfig describes how a Valkey instance persists data and the PersistentVolumeClaims backing it.
+type PersistenceConfig struct {
+	Profile      PersistenceProfile `json:"profile"`
+	AppendFsync  AppendFsync        `json:"appendFsync"`
+	Size         string             `json:"size"`
+	StorageClass string             `json:"storageClass"`
+	// WhenDeleted controls what happens to the PersistentVolumeClaims when the StatefulSet is deleted.
+	WhenDeleted PVCRetentionPolicy `json:"whenDeleted"`
+	// WhenScaled controls what happens to the PersistentVolumeClaims when the StatefulSet is scaled down.
+	WhenScaled PVCRetentionPolicy `json:"whenScaled"`
+}
+
+// newDefaultPersistenceConfig returns the persistence settings used when the stack config doesn't override them. They
+// match the RDB-only schedule Valkey ships with.
+func newDefaultPersistenceConfig() *PersistenceConfig {
+	return &PersistenceConfig{
+		Profile:     PersistenceRDB,
+		AppendFsync: AppendFsyncEverySec,
+		Size:        "8Gi",
+		WhenDeleted: PVCRetain,
+		WhenScaled:  PVCRetain,
+	}
+}
+
+// RDBEnabled reports whether RDB snapshots are enabled.
+func (p *PersistenceConfig) RDBEnabled() bool {
+	return p.Profile == PersistenceRDB || p.Profile == PersistenceRDBAndAOF
+}
+
+// AOFEnabled reports whether the append only file is enabled.
+func (p *PersistenceConfig) AOFEnabled() bool {
+	return p.Profile == PersistenceAOF || p.Profile == PersistenceRDBAndAOF
+}
+
+// VolumeEnabled reports whether the pods need a PersistentVolumeClaim.
+func (p *PersistenceConfig) VolumeEnabled() bool {
+	return p.Profile != PersistenceNone
+}
+
+// validate rejects unknown profiles and policies, and volume settings that can't be honoured.
+func (p *PersistenceConfig) validate() error {
+	switch p.Profile {
+	case PersistenceNone, PersistenceRDB, PersistenceAOF, PersistenceRDBAndAOF:
+	default:
+		return fmt.Errorf("%w: unknown profile %q", ErrInvalidPersistenceConfig, p.Profile)
+	}
+	switch p.AppendFsync {
+	case AppendFsyncAlways, AppendFsyncEverySec, AppendFsyncNo:
+	default:
+		return fmt.Errorf("%w: unknown appendfsync policy %q", ErrInvalidPersistenceConfig, p.AppendFsync)
+	}
+	for _, policy := range []PVCRetentionPolicy{p.WhenDeleted, p.WhenScaled} {
+		if policy != PVCRetain && policy != PVCDelete {
+			return fmt.Errorf("%w: unknown PVC retention policy %q", ErrInvalidPersistenceConfig, policy)
+		}
+	}
+	if p.VolumeEnabled() && p.Size == "" {
+		return fmt.Errorf("%w: profile %s requires a volume size", ErrInvalidPersistenceConfig, p.Profile)
+	}
+	return nil
+}
+
+// newValkeyPersistenceValues returns the chart values that configure the data volume of the Valkey pods.
+func newValkeyPersistenceValues(p *PersistenceConfig) (pulumi.Map, pulumi.Map) {
+	persistence := pulumi.Map{
+		"enabled": pulumi.Bool(p.VolumeEnabled()),
+		"size":    pulumi.String(p.Size),
+	}
+	if p.StorageClass != "" {
+		persistence["storageClass"] = pulumi.String(p.StorageClass)
+	}
+	retention := pulumi.Map{
+		"enabled":     pulumi.Bool(p.VolumeEnabled()),
+		"whenDeleted": pulumi.String(p.WhenDeleted),
+		"whenScaled":  pulumi.String(p.WhenScaled),
+	}
+	return persistence, retention
+}
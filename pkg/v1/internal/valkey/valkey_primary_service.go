@@ -0,0 +1,203 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// roleLabel is kept up to date on every Valkey pod of a [ModeReplication] instance by the role labeler sidecar, so
+	// that the primary Services only select the current primary.
+	roleLabel                = "valkey.fjarm.io/role"
+	roleLabelerComponent     = "role-labeler"
+	roleLabelerContainerName = "role-labeler"
+	roleLabelerInterval      = 2
+)
+
+// roleLabelerScript labels the pod it runs in with its replication role whenever the role changes. The label is only
+// recorded as applied once kubectl succeeds, so that a failed patch is retried on the next tick.
+const roleLabelerScript = `#!/bin/bash
+last=""
+while true; do
+  role="$(valkey-cli --tls --cacert "%[1]s/ca.crt" --cert "%[1]s/tls.crt" --key "%[1]s/tls.key" \
+    -h 127.0.0.1 -p %[2]d --user "%[3]s" INFO replication 2>/dev/null | tr -d '\r' | sed -n 's/^role://p')"
+  case "${role}" in
+    master) role="primary" ;;
+    slave) role="replica" ;;
+    *) role="" ;;
+  esac
+  if [ -n "${role}" ] && [ "${role}" != "${last}" ]; then
+    if %[4]s/kubectl label pod "${POD_NAME}" --overwrite "%[5]s=${role}"; then
+      last="${role}"
+    fi
+  fi
+  sleep %[6]d
+done
+`
+
+// labelsRoles reports whether the Valkey pods of the instance run the role labeler sidecar. Other backends select their
+// primary themselves.
+func (spec *InstanceSpec) labelsRoles() bool {
+	return spec.Backend == BackendValkey && spec.Mode == ModeReplication
+}
+
+// deployValkeyPrimaryService deploys the Service selecting the current primary of a [ModeReplication] instance. It
+// follows a failover once the role labeler relabels the pods, which takes up to [roleLabelerInterval] seconds.
+func deployValkeyPrimaryService(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (*corev1.Service, error) {
+	return corev1.NewService(
+		ctx,
+		primaryServiceName(name),
+		&corev1.ServiceArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(primaryServiceName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newValkeyInstanceLabels(name),
+			},
+			Spec: &corev1.ServiceSpecArgs{
+				Type:     pulumi.String("ClusterIP"),
+				Selector: newValkeyPrimarySelectorLabels(name, spec),
+				Ports: corev1.ServicePortArray{
+					&corev1.ServicePortArgs{
+						Name:       pulumi.String("tcp"),
+						Port:       pulumi.Int(valkeyPort),
+						TargetPort: pulumi.Int(valkeyPort),
+						Protocol:   pulumi.String("TCP"),
+					},
+				},
+			},
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+}
+
+// newValkeyPrimarySelectorLabels returns the labels selecting the current primary of an instance.
+func newValkeyPrimarySelectorLabels(name string, spec *InstanceSpec) pulumi.StringMap {
+	selector := newValkeyPodSelectorLabels(name, spec)
+	selector[roleLabel] = pulumi.String("primary")
+	return selector
+}
+
+// deployValkeyRoleLabelerRBAC deploys the ServiceAccount the Valkey pods run as, which lets the role labeler sidecar
+// label its own pod. The role is limited to the pods of the instance's StatefulSet.
+func deployValkeyRoleLabelerRBAC(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	pods := pulumi.StringArray{}
+	for i := 0; i < spec.Replicas; i++ {
+		pods = append(pods, pulumi.String(fmt.Sprintf("%s-%d", nodeStatefulSetName(name), i)))
+	}
+	rules := rbacv1.PolicyRuleArray{
+		&rbacv1.PolicyRuleArgs{
+			ApiGroups:     pulumi.StringArray{pulumi.String("")},
+			Resources:     pulumi.StringArray{pulumi.String("pods")},
+			ResourceNames: pods,
+			Verbs: pulumi.StringArray{
+				pulumi.String("get"),
+				pulumi.String("patch"),
+			},
+		},
+	}
+	return deployValkeyToolingRBAC(ctx, name, roleLabelerComponent, namespace, rules, provider, deps)
+}
+
+// newValkeyRoleLabelerSidecar returns the container spec of the sidecar keeping [roleLabel] up to date.
+func newValkeyRoleLabelerSidecar(name string) pulumi.Map {
+	script := fmt.Sprintf(
+		roleLabelerScript,
+		tlsMountPath,
+		valkeyPort,
+		operatorUsername,
+		toolingBinPath,
+		roleLabel,
+		roleLabelerInterval,
+	)
+	return pulumi.Map{
+		"name":    pulumi.String(roleLabelerContainerName),
+		"image":   pulumi.String(fmt.Sprintf("%s@%s", imageRepository, imageDigest)),
+		"command": pulumi.StringArray{pulumi.String("/bin/bash"), pulumi.String("-c")},
+		"args":    pulumi.StringArray{pulumi.String(script)},
+		"env": pulumi.Array{
+			pulumi.Map{
+				"name": pulumi.String(valkeyCLIAuthEnv),
+				"valueFrom": pulumi.Map{
+					"secretKeyRef": pulumi.Map{
+						"name": pulumi.String(aclSecretName(name)),
+						"key":  pulumi.String(operatorSecretKey),
+					},
+				},
+			},
+			pulumi.Map{
+				"name": pulumi.String("POD_NAME"),
+				"valueFrom": pulumi.Map{
+					"fieldRef": pulumi.Map{
+						"fieldPath": pulumi.String("metadata.name"),
+					},
+				},
+			},
+		},
+		"resources": pulumi.Map{
+			"limits": pulumi.Map{
+				"cpu":    pulumi.String("50m"),
+				"memory": pulumi.String("64Mi"),
+			},
+			"requests": pulumi.Map{
+				"cpu":    pulumi.String("10m"),
+				"memory": pulumi.String("32Mi"),
+			},
+		},
+		"volumeMounts": pulumi.Array{
+			pulumi.Map{
+				"name":      pulumi.String(tlsVolumeName),
+				"mountPath": pulumi.String(tlsMountPath),
+				"readOnly":  pulumi.Bool(true),
+			},
+			pulumi.Map{
+				"name":      pulumi.String(toolingBinVolume),
+				"mountPath": pulumi.String(toolingBinPath),
+				"readOnly":  pulumi.Bool(true),
+			},
+		},
+	}
+}
+
+// newValkeyKubectlInitContainer returns the chart values of the init container copying kubectl into the volume the
+// role labeler sidecar runs it from.
+func newValkeyKubectlInitContainer() pulumi.Map {
+	return pulumi.Map{
+		"name":  pulumi.String("kubectl"),
+		"image": pulumi.String(kubectlImage),
+		"command": pulumi.StringArray{
+			pulumi.String("cp"),
+			pulumi.String(kubectlPath),
+			pulumi.String(toolingBinPath + "/kubectl"),
+		},
+		"volumeMounts": pulumi.Array{
+			pulumi.Map{
+				"name":      pulumi.String(toolingBinVolume),
+				"mountPath": pulumi.String(toolingBinPath),
+			},
+		},
+	}
+}
+
+// primaryServiceName returns the name of the Service selecting the current primary of an instance.
+func primaryServiceName(name string) string {
+	return fmt.Sprintf("%s-primary", name)
+}
@@ -0,0 +1,628 @@
+package valkey
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/fjarm/infrastructure/pkg/v1/certmanager"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
+	policyv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/policy/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"strings"
+)
+
+// ErrInvalidProxyConfig is returned when the proxy is enabled with settings it can't run with.
+var ErrInvalidProxyConfig = fmt.Errorf("invalid proxy config")
+
+const (
+	proxyComponent = "proxy"
+	// proxyImage is the Envoy image of the proxy. The tag only documents the version, since the image is pinned by the
+	// digest in [ProxyConfig.ImageDigest].
+	proxyImage = "docker.io/envoyproxy/envoy:v1.34.1"
+	// proxyChecksumAnnotation records the checksum of the Envoy config and passwords a proxy pod was started with, so
+	// that the pods restart when either changes. Envoy only reads the password files at startup.
+	proxyChecksumAnnotation = "valkey.fjarm.io/proxy-checksum"
+	proxyClusterName        = "valkey_primary"
+	proxyConfigKey          = "envoy.yaml"
+	proxyConfigPath         = "/etc/envoy/config"
+	proxyConfigVolume       = "config"
+	proxyAuthPath           = "/etc/envoy/auth"
+	proxyAuthVolume         = "auth"
+	proxyEndpointsFile      = "primary.json"
+	proxyEndpointsPath      = "/etc/envoy/endpoints"
+	proxyEndpointsVolume    = "endpoints"
+	proxyTLSPath            = "/etc/envoy/tls"
+	proxyTLSVolume          = "proxy-tls"
+	proxyUpstreamKey        = "upstream-password"
+	proxyWatcherName        = "primary-watcher"
+	proxyWatcherInterval    = 1
+)
+
+// proxyEnvoyConfig is the Envoy bootstrap config of the proxy. Clients authenticate to Envoy as the proxy user, and
+// their commands are multiplexed over a small pool of upstream connections to the current primary, which Envoy
+// authenticates as the same user. The upstream endpoint is read from [proxyEndpointsFile], which the primary watcher
+// rewrites whenever sentinel promotes another primary.
+const proxyEnvoyConfig = `static_resources:
+  listeners:
+  - name: valkey
+    address:
+      socket_address: {address: 0.0.0.0, port_value: %[1]d}
+    filter_chains:
+    - filters:
+      - name: envoy.filters.network.redis_proxy
+        typed_config:
+          "@type": type.googleapis.com/envoy.extensions.filters.network.redis_proxy.v3.RedisProxy
+          stat_prefix: valkey
+          settings:
+            op_timeout: %[2]ds
+            enable_command_stats: true
+          prefix_routes:
+            catch_all_route:
+              cluster: %[3]s
+          downstream_auth_username: {inline_string: "%[4]s"}
+          downstream_auth_passwords:
+%[5]s
+      transport_socket:
+        name: envoy.transport_sockets.tls
+        typed_config:
+          "@type": type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.DownstreamTlsContext
+          common_tls_context:
+            tls_certificates:
+            - certificate_chain: {filename: "%[6]s/tls.crt"}
+              private_key: {filename: "%[6]s/tls.key"}
+  clusters:
+  - name: %[3]s
+    type: EDS
+    connect_timeout: 5s
+    eds_cluster_config:
+      eds_config:
+        resource_api_version: V3
+        path_config_source:
+          path: "%[7]s/%[8]s"
+          watched_directory: {path: "%[7]s"}
+    typed_extension_protocol_options:
+      envoy.filters.network.redis_proxy:
+        "@type": type.googleapis.com/envoy.extensions.filters.network.redis_proxy.v3.RedisProtocolOptions
+        auth_username: {inline_string: "%[4]s"}
+        auth_password: {filename: "%[9]s/%[10]s"}
+    transport_socket:
+      name: envoy.transport_sockets.tls
+      typed_config:
+        "@type": type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext
+        sni: "%[11]s"
+        common_tls_context:
+          tls_certificates:
+          - certificate_chain: {filename: "%[12]s/tls.crt"}
+            private_key: {filename: "%[12]s/tls.key"}
+          validation_context:
+            trusted_ca: {filename: "%[12]s/ca.crt"}
+            match_typed_subject_alt_names:
+            - san_type: DNS
+              matcher: {exact: "%[11]s"}
+admin:
+  address:
+    socket_address: {address: 127.0.0.1, port_value: 9901}
+`
+
+// proxyWatcherScript asks sentinel for the current primary and writes its IP to [proxyEndpointsFile] as an Envoy
+// endpoint discovery response. The file is written next to its destination and moved into place, since Envoy only
+// reloads files moved into the watched directory. With `--once` the script exits after the first write, which lets
+// an init container make sure the file exists before Envoy starts.
+const proxyWatcherScript = `set -euo pipefail
+resolve() {
+  primary="$(VALKEYCLI_AUTH="${%[1]s}" valkey-cli --tls --cacert "%[2]s/ca.crt" --cert "%[2]s/tls.crt" \
+    --key "%[2]s/tls.key" -h "%[3]s" -p %[4]d SENTINEL get-master-addr-by-name "%[5]s" 2>/dev/null | head -n 1)"
+  [ -n "${primary}" ] || return 1
+  ip="$(getent hosts "${primary}" | awk '{ print $1; exit }')"
+  [ -n "${ip}" ]
+}
+write_endpoints() {
+  cat > "%[6]s/.%[7]s" <<EOF
+{
+  "version_info": "${ip}",
+  "resources": [{
+    "@type": "type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment",
+    "cluster_name": "%[8]s",
+    "endpoints": [{"lb_endpoints": [{"endpoint": {"address": {"socket_address": {
+      "address": "${ip}",
+      "port_value": %[9]d
+    }}}}]}]
+  }]
+}
+EOF
+  mv "%[6]s/.%[7]s" "%[6]s/%[7]s"
+  echo "routing to primary ${primary} at ${ip}"
+}
+until resolve; do
+  sleep %[10]d
+done
+write_endpoints
+if [ "${1:-}" = "--once" ]; then
+  exit 0
+fi
+last="${ip}"
+while true; do
+  sleep %[10]d
+  if resolve && [ "${ip}" != "${last}" ]; then
+    write_endpoints
+    last="${ip}"
+  fi
+done
+`
+
+// ProxyConfig puts an Envoy Redis proxy in front of a [ModeReplication] instance. Clients connect to a single stable
+// Service over TLS with a certificate from the internal cluster issuer, and Envoy pools their commands onto a few
+// upstream connections to whichever pod sentinel reports as the primary.
+//
+// Every pooled connection authenticates as the user named by Username, so clients have to log in as that user and
+// share its ACL. Envoy doesn't proxy blocking commands, pub/sub, or transactions spanning several keys, so clients
+// needing them keep connecting to the instance directly.
+type ProxyConfig struct {
+	Enabled  bool `json:"enabled"`
+	Replicas int  `json:"replicas"`
+	// Username names the user of the instance the proxy authenticates clients and upstream connections as.
+	Username string `json:"username"`
+	// Concurrency is the number of Envoy worker threads of a proxy pod. Each worker keeps its own upstream connection.
+	Concurrency int `json:"concurrency"`
+	// OpTimeoutSeconds bounds how long a command may wait for the primary's reply.
+	OpTimeoutSeconds int `json:"opTimeoutSeconds"`
+	// ImageDigest pins the [proxyImage] Envoy runs. It is required, since the tag is mutable.
+	ImageDigest string `json:"imageDigest"`
+	// Resources are the requests and limits of the Envoy container.
+	Resources *ResourcesConfig `json:"resources"`
+}
+
+// newDefaultProxyConfig returns the disabled proxy config used when the stack config doesn't enable it.
+func newDefaultProxyConfig() *ProxyConfig {
+	return &ProxyConfig{
+		Replicas:         2,
+		Concurrency:      2,
+		OpTimeoutSeconds: 5,
+		Resources: &ResourcesConfig{
+			CPURequest:    "100m",
+			CPULimit:      "500m",
+			MemoryRequest: "64Mi",
+			MemoryLimit:   "128Mi",
+		},
+	}
+}
+
+// validateProxy checks that an enabled proxy runs in [ModeReplication] as one of the instance's users, with a pinned
+// image and complete resources.
+func (spec *InstanceSpec) validateProxy() error {
+	proxy := spec.Proxy
+	if !proxy.Enabled {
+		return nil
+	}
+	if spec.Mode != ModeReplication {
+		return fmt.Errorf("%w: cluster mode clients follow MOVED redirects themselves", ErrInvalidProxyConfig)
+	}
+	if proxy.Replicas < 1 || proxy.Concurrency < 1 || proxy.OpTimeoutSeconds < 1 {
+		return fmt.Errorf(
+			"%w: replicas, concurrency, and opTimeoutSeconds must be positive",
+			ErrInvalidProxyConfig,
+		)
+	}
+	if spec.proxyUser() == nil {
+		return fmt.Errorf("%w: the proxy user %q isn't a user of the instance", ErrInvalidProxyConfig, proxy.Username)
+	}
+	resources := proxy.Resources
+	if resources == nil || resources.CPURequest == "" || resources.CPULimit == "" ||
+		resources.MemoryRequest == "" || resources.MemoryLimit == "" {
+		return fmt.Errorf("%w: the proxy requires requests and limits", ErrInvalidProxyConfig)
+	}
+	return validateImageDigest(proxyImage, proxy.ImageDigest)
+}
+
+// proxyUser returns the user the proxy authenticates as, or nil if the instance has no such user.
+func (spec *InstanceSpec) proxyUser() *valkeyUser {
+	for _, user := range spec.Users {
+		if user.Username == spec.Proxy.Username {
+			return user
+		}
+	}
+	return nil
+}
+
+// deployValkeyProxy deploys the proxy's certificate, password Secret, config, Deployment, and Service, and a
+// PodDisruptionBudget keeping all but one proxy pod up during drains when there are several.
+func deployValkeyProxy(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	cert, err := apiextensions.NewCustomResource(
+		ctx,
+		proxyCertificateName(name),
+		newValkeyProxyCertificateArgs(name, namespace, spec),
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	user := spec.proxyUser()
+	passwords := pulumi.StringMap{proxyUpstreamKey: pulumi.String(user.PublishedPassword())}
+	for i, password := range user.ActivePasswords() {
+		passwords[proxyPasswordKey(i)] = pulumi.String(password)
+	}
+	secret, err := corev1.NewSecret(
+		ctx,
+		proxyAuthSecretName(name),
+		&corev1.SecretArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(proxyAuthSecretName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newValkeyToolingLabels(name, proxyComponent),
+			},
+			Type:       pulumi.String("Opaque"),
+			StringData: pulumi.ToSecret(passwords).(pulumi.StringMapOutput),
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	config := newValkeyProxyEnvoyConfig(name, spec)
+	configMap, err := corev1.NewConfigMap(
+		ctx,
+		proxyServiceName(name),
+		&corev1.ConfigMapArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(proxyServiceName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newValkeyToolingLabels(name, proxyComponent),
+			},
+			Data: pulumi.StringMap{proxyConfigKey: pulumi.String(config)},
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []pulumi.Resource{cert, secret, configMap}
+	deployment, err := appsv1.NewDeployment(
+		ctx,
+		proxyServiceName(name),
+		newValkeyProxyDeploymentArgs(name, namespace, spec, config),
+		pulumi.Provider(provider),
+		pulumi.DependsOn(append(deps, resources...)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := newValkeyToolingLabels(name, proxyComponent)
+	service, err := corev1.NewService(
+		ctx,
+		proxyServiceName(name),
+		&corev1.ServiceArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(proxyServiceName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    labels,
+			},
+			Spec: &corev1.ServiceSpecArgs{
+				Selector: labels,
+				Ports: corev1.ServicePortArray{
+					&corev1.ServicePortArgs{
+						Name:       pulumi.String("tcp"),
+						Port:       pulumi.Int(valkeyPort),
+						TargetPort: pulumi.Int(valkeyPort),
+						Protocol:   pulumi.String("TCP"),
+					},
+				},
+			},
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	resources = append(resources, deployment, service)
+
+	if spec.Proxy.Replicas > 1 {
+		pdb, err := policyv1.NewPodDisruptionBudget(
+			ctx,
+			proxyPodDisruptionBudgetName(name),
+			&policyv1.PodDisruptionBudgetArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Name:      pulumi.String(proxyPodDisruptionBudgetName(name)),
+					Namespace: namespace.Metadata.Name(),
+					Labels:    labels,
+				},
+				Spec: &policyv1.PodDisruptionBudgetSpecArgs{
+					Selector: &metav1.LabelSelectorArgs{
+						MatchLabels: labels,
+					},
+					MaxUnavailable: pulumi.Int(1),
+				},
+			},
+			pulumi.Provider(provider),
+			pulumi.DependsOn(deps),
+		)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, pdb)
+	}
+	return resources, nil
+}
+
+// newValkeyProxyCertificateArgs returns the Certificate the proxy terminates client TLS with. It is issued by the
+// same internal cluster issuer as the instance certificate, so clients verify it with the instance's CA bundle.
+func newValkeyProxyCertificateArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+) *apiextensions.CustomResourceArgs {
+	service := proxyServiceName(name)
+	namespaceName := spec.namespaceName(name)
+	return &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("cert-manager.io/v1"),
+		Kind:       pulumi.String("Certificate"),
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String(proxyCertificateName(name)),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    newValkeyToolingLabels(name, proxyComponent),
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": kubernetes.UntypedArgs{
+				"commonName": pulumi.String(service),
+				"dnsNames": pulumi.StringArray{
+					pulumi.String(fmt.Sprintf("%s.%s.svc.cluster.local", service, namespaceName)),
+					pulumi.String(fmt.Sprintf("%s.%s.svc", service, namespaceName)),
+					pulumi.String(fmt.Sprintf("%s.%s", service, namespaceName)),
+					pulumi.String(service),
+				},
+				"duration": pulumi.String("87600h0m0s"),
+				"issuerRef": kubernetes.UntypedArgs{
+					"kind":  pulumi.String("ClusterIssuer"),
+					"name":  pulumi.String(certmanager.InternalClusterIssuerName),
+					"group": pulumi.String("cert-manager.io"),
+				},
+				"secretName": pulumi.String(proxyCertificateSecretName(name)),
+				"usages": pulumi.StringArray{
+					pulumi.String("server auth"),
+					pulumi.String("signing"),
+					pulumi.String("key encipherment"),
+				},
+			},
+		},
+	}
+}
+
+// newValkeyProxyEnvoyConfig returns [proxyEnvoyConfig] bound to an instance.
+func newValkeyProxyEnvoyConfig(name string, spec *InstanceSpec) string {
+	var passwords []string
+	for i := range spec.proxyUser().ActivePasswords() {
+		passwords = append(passwords, fmt.Sprintf(
+			"          - filename: \"%s/%s\"",
+			proxyAuthPath,
+			proxyPasswordKey(i),
+		))
+	}
+	return fmt.Sprintf(
+		proxyEnvoyConfig,
+		valkeyPort,
+		spec.Proxy.OpTimeoutSeconds,
+		proxyClusterName,
+		spec.Proxy.Username,
+		strings.Join(passwords, "\n"),
+		proxyTLSPath,
+		proxyEndpointsPath,
+		proxyEndpointsFile,
+		proxyAuthPath,
+		proxyUpstreamKey,
+		fmt.Sprintf("%s.%s.svc.cluster.local", name, spec.namespaceName(name)),
+		toolingTLSPath,
+	)
+}
+
+// newValkeyProxyDeploymentArgs returns the Deployment running Envoy next to the primary watcher. An init container
+// resolves the primary once before Envoy starts, so that its endpoints file exists.
+func newValkeyProxyDeploymentArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	config string,
+) *appsv1.DeploymentArgs {
+	labels := newValkeyToolingLabels(name, proxyComponent)
+	script := fmt.Sprintf(
+		proxyWatcherScript,
+		sentinelPasswordEnv,
+		toolingTLSPath,
+		fmt.Sprintf("%s.%s.svc.cluster.local", name, spec.namespaceName(name)),
+		sentinelPort,
+		sentinelMasterSet,
+		proxyEndpointsPath,
+		proxyEndpointsFile,
+		proxyClusterName,
+		valkeyPort,
+		proxyWatcherInterval,
+	)
+	env := corev1.EnvVarArray{newSecretKeyEnvVar(sentinelPasswordEnv, name, sentinelPasswordKey)}
+	endpointsMount := &corev1.VolumeMountArgs{
+		Name:      pulumi.String(proxyEndpointsVolume),
+		MountPath: pulumi.String(proxyEndpointsPath),
+	}
+	initWatcher := newValkeyToolingContainer(name, proxyWatcherName+"-init", script, env, endpointsMount)
+	initWatcher.Args = pulumi.StringArray{
+		pulumi.String(script),
+		pulumi.String(proxyWatcherName),
+		pulumi.String("--once"),
+	}
+	checksum := sha256.New()
+	checksum.Write([]byte(config))
+	for _, password := range append(spec.proxyUser().ActivePasswords(), spec.proxyUser().PublishedPassword()) {
+		checksum.Write([]byte{0})
+		checksum.Write([]byte(password))
+	}
+
+	return &appsv1.DeploymentArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(proxyServiceName(name)),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    labels,
+		},
+		Spec: &appsv1.DeploymentSpecArgs{
+			Replicas: pulumi.Int(spec.Proxy.Replicas),
+			Selector: &metav1.LabelSelectorArgs{
+				MatchLabels: labels,
+			},
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: labels,
+					Annotations: pulumi.StringMap{
+						proxyChecksumAnnotation: pulumi.String(fmt.Sprintf("%x", checksum.Sum(nil))),
+					},
+				},
+				Spec: &corev1.PodSpecArgs{
+					SecurityContext: newValkeyToolingPodSecurityContext(),
+					InitContainers:  corev1.ContainerArray{initWatcher},
+					Containers: corev1.ContainerArray{
+						&corev1.ContainerArgs{
+							Name:  pulumi.String(proxyComponent),
+							Image: pulumi.String(fmt.Sprintf("%s@%s", proxyImage, spec.Proxy.ImageDigest)),
+							Args: pulumi.StringArray{
+								pulumi.String("--config-path"),
+								pulumi.String(fmt.Sprintf("%s/%s", proxyConfigPath, proxyConfigKey)),
+								pulumi.String("--concurrency"),
+								pulumi.String(fmt.Sprintf("%d", spec.Proxy.Concurrency)),
+							},
+							Ports: corev1.ContainerPortArray{
+								&corev1.ContainerPortArgs{
+									Name:          pulumi.String("tcp"),
+									ContainerPort: pulumi.Int(valkeyPort),
+								},
+							},
+							Resources: spec.Proxy.Resources.requirements(),
+							ReadinessProbe: &corev1.ProbeArgs{
+								TcpSocket: &corev1.TCPSocketActionArgs{
+									Port: pulumi.Int(valkeyPort),
+								},
+							},
+							SecurityContext: &corev1.SecurityContextArgs{
+								RunAsUser:                pulumi.Int(valkeyRunAsUser),
+								RunAsNonRoot:             pulumi.Bool(true),
+								AllowPrivilegeEscalation: pulumi.Bool(false),
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								&corev1.VolumeMountArgs{
+									Name:      pulumi.String(proxyConfigVolume),
+									MountPath: pulumi.String(proxyConfigPath),
+									ReadOnly:  pulumi.Bool(true),
+								},
+								&corev1.VolumeMountArgs{
+									Name:      pulumi.String(proxyAuthVolume),
+									MountPath: pulumi.String(proxyAuthPath),
+									ReadOnly:  pulumi.Bool(true),
+								},
+								&corev1.VolumeMountArgs{
+									Name:      pulumi.String(proxyTLSVolume),
+									MountPath: pulumi.String(proxyTLSPath),
+									ReadOnly:  pulumi.Bool(true),
+								},
+								&corev1.VolumeMountArgs{
+									Name:      pulumi.String(toolingTLSVolume),
+									MountPath: pulumi.String(toolingTLSPath),
+									ReadOnly:  pulumi.Bool(true),
+								},
+								&corev1.VolumeMountArgs{
+									Name:      pulumi.String(proxyEndpointsVolume),
+									MountPath: pulumi.String(proxyEndpointsPath),
+									ReadOnly:  pulumi.Bool(true),
+								},
+							},
+						},
+						newValkeyToolingContainer(name, proxyWatcherName, script, env, endpointsMount),
+					},
+					Volumes: append(
+						newValkeyToolingVolumes(name),
+						&corev1.VolumeArgs{
+							Name: pulumi.String(proxyConfigVolume),
+							ConfigMap: &corev1.ConfigMapVolumeSourceArgs{
+								Name: pulumi.String(proxyServiceName(name)),
+							},
+						},
+						&corev1.VolumeArgs{
+							Name: pulumi.String(proxyAuthVolume),
+							Secret: &corev1.SecretVolumeSourceArgs{
+								SecretName: pulumi.String(proxyAuthSecretName(name)),
+							},
+						},
+						&corev1.VolumeArgs{
+							Name: pulumi.String(proxyTLSVolume),
+							Secret: &corev1.SecretVolumeSourceArgs{
+								SecretName: pulumi.String(proxyCertificateSecretName(name)),
+							},
+						},
+						&corev1.VolumeArgs{
+							Name:     pulumi.String(proxyEndpointsVolume),
+							EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+						},
+					),
+				},
+			},
+		},
+	}
+}
+
+// newValkeyAllowProxyClientsPolicySpec allows the instance's configured clients to reach the proxy.
+func newValkeyAllowProxyClientsPolicySpec(name string, spec *InstanceSpec) *networkingv1.NetworkPolicySpecArgs {
+	return &networkingv1.NetworkPolicySpecArgs{
+		PodSelector: &metav1.LabelSelectorArgs{
+			MatchLabels: newValkeyToolingLabels(name, proxyComponent),
+		},
+		PolicyTypes: pulumi.StringArray{pulumi.String("Ingress")},
+		Ingress: networkingv1.NetworkPolicyIngressRuleArray{
+			&networkingv1.NetworkPolicyIngressRuleArgs{
+				From:  newAllowedClientPeers(spec),
+				Ports: newNetworkPolicyPorts(valkeyPort),
+			},
+		},
+	}
+}
+
+// proxyPasswordKey returns the key of the proxy auth Secret holding one of the passwords clients may log in with.
+func proxyPasswordKey(index int) string {
+	return fmt.Sprintf("password-%d", index)
+}
+
+// proxyServiceName returns the name of the proxy's Service, Deployment, and ConfigMap.
+func proxyServiceName(name string) string {
+	return fmt.Sprintf("%s-%s", name, proxyComponent)
+}
+
+// proxyPodDisruptionBudgetName returns the name of the proxy's PodDisruptionBudget.
+func proxyPodDisruptionBudgetName(name string) string {
+	return fmt.Sprintf("%s-%s-pdb", name, proxyComponent)
+}
+
+// proxyAuthSecretName returns the name of the Secret holding the passwords of the proxy user.
+func proxyAuthSecretName(name string) string {
+	return fmt.Sprintf("%s-%s-auth", name, proxyComponent)
+}
+
+// proxyCertificateName returns the name of the proxy's Certificate.
+func proxyCertificateName(name string) string {
+	return fmt.Sprintf("%s-%s-certificate", name, proxyComponent)
+}
+
+// proxyCertificateSecretName returns the name of the Secret cert-manager writes the proxy's certificate to.
+func proxyCertificateSecretName(name string) string {
+	return fmt.Sprintf("%s-%s-tls-secret", name, proxyComponent)
+}
@@ -0,0 +1,67 @@
+package valkey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInstanceSpecValidateProxy(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(spec *InstanceSpec)
+		want   error
+	}{
+		{
+			name:   "disabled",
+			modify: func(spec *InstanceSpec) { spec.Proxy.Enabled = false },
+		},
+		{
+			name:   "enabled",
+			modify: func(*InstanceSpec) {},
+		},
+		{
+			name:   "cluster mode",
+			modify: func(spec *InstanceSpec) { spec.Mode = ModeCluster },
+			want:   ErrInvalidProxyConfig,
+		},
+		{
+			name:   "no replicas",
+			modify: func(spec *InstanceSpec) { spec.Proxy.Replicas = 0 },
+			want:   ErrInvalidProxyConfig,
+		},
+		{
+			name:   "unknown user",
+			modify: func(spec *InstanceSpec) { spec.Proxy.Username = "other" },
+			want:   ErrInvalidProxyConfig,
+		},
+		{
+			name:   "no resources",
+			modify: func(spec *InstanceSpec) { spec.Proxy.Resources = nil },
+			want:   ErrInvalidProxyConfig,
+		},
+		{
+			name:   "incomplete resources",
+			modify: func(spec *InstanceSpec) { spec.Proxy.Resources = &ResourcesConfig{CPURequest: "100m"} },
+			want:   ErrInvalidProxyConfig,
+		},
+		{
+			name:   "unpinned image",
+			modify: func(spec *InstanceSpec) { spec.Proxy.ImageDigest = "v1.34.1" },
+			want:   ErrUnpinnedImage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := newTestInstanceSpec()
+			spec.Users = []*valkeyUser{{Username: "app", Password: "app-password"}}
+			spec.Proxy.Enabled = true
+			spec.Proxy.Username = "app"
+			spec.Proxy.ImageDigest = testImageDigest
+			tt.modify(spec)
+			err := spec.validateProxy()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validateProxy() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
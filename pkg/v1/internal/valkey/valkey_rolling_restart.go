@@ -0,0 +1,353 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	coordinationv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/coordination/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	kubectlImage          = "docker.io/bitnami/kubectl:1.33.1"
+	kubectlPath           = "/opt/bitnami/kubectl/bin/kubectl"
+	sentinelPasswordEnv   = "SENTINEL_PASSWORD"
+	sentinelPasswordKey   = "valkey-password"
+	rollingRestartTimeout = 600
+	// restartLockUnlocked is the holder of the restart lock while no Job is restarting the Valkey pods.
+	restartLockUnlocked = "unlocked"
+)
+
+// rollingRestartScript defines the bash functions used to restart the Valkey pods of a [ModeReplication] instance one
+// at a time. Replicas are restarted first and each one has to resync before the next is touched. The primary goes
+// last: once every replica's offset is within the allowed lag of the primary's, sentinel fails it over to a replica,
+// and the old primary is only restarted once the new one has held the master role for the stabilisation window.
+// Callers run `rolling_restart` after sourcing the functions, optionally overriding `needs_restart` to skip pods.
+//
+// The upgrade Job and the certificate reload CronJob both restart the pods, so `rolling_restart` first takes the
+// instance's restart lock, a Lease held by the pod name of the Job. The holder is swapped with a JSON patch testing
+// the previous holder, so only one Job can take the lock. A lock whose holder pod is no longer running is taken over.
+const rollingRestartScript = `kubectl() {
+  %[1]s/kubectl --namespace "%[12]s" "$@"
+}
+lock_patch() {
+  printf '[{"op":"test","path":"/spec/holderIdentity","value":"%%s"},' "$1"
+  printf '{"op":"replace","path":"/spec/holderIdentity","value":"%%s"}]' "$2"
+}
+acquire_lock() {
+  while true; do
+    holder="$(kubectl get lease "%[15]s" -o jsonpath='{.spec.holderIdentity}')"
+    if [ "${holder}" != "%[16]s" ] && [ "${holder}" != "${HOSTNAME}" ] &&
+      [ "$(kubectl get pod "${holder}" -o jsonpath='{.status.phase}' 2>/dev/null)" = "Running" ]; then
+      echo "waiting for ${holder} to finish restarting the pods"
+      sleep 10
+      continue
+    fi
+    if kubectl patch lease "%[15]s" --type=json -p "$(lock_patch "${holder}" "${HOSTNAME}")" >/dev/null; then
+      trap release_lock EXIT
+      return 0
+    fi
+  done
+}
+release_lock() {
+  kubectl patch lease "%[15]s" --type=json -p "$(lock_patch "${HOSTNAME}" "%[16]s")" >/dev/null || true
+}
+sentinel_cli() {
+  VALKEYCLI_AUTH="${%[2]s}" valkey-cli --tls --cacert "%[3]s/ca.crt" --cert "%[3]s/tls.crt" --key "%[3]s/tls.key" \
+    -h "%[4]s" -p %[5]d "$@"
+}
+primary_host() {
+  sentinel_cli SENTINEL get-master-addr-by-name "%[6]s" | head -n 1
+}
+pod_host() {
+  echo "$1.%[7]s"
+}
+is_pod_host() {
+  case "$2" in
+    "$1"|"$1".*) return 0 ;;
+  esac
+  return 1
+}
+needs_restart() {
+  return 0
+}
+wait_ready() {
+  until kubectl get pod "$1" >/dev/null 2>&1; do
+    sleep 2
+  done
+  kubectl wait --for=condition=Ready "pod/$1" --timeout=%[8]ds
+  until cli -h "$(pod_host "$1")" -p %[9]d PING | grep -q PONG; do
+    sleep 2
+  done
+}
+wait_synced() {
+  until cli -h "$(pod_host "$1")" -p %[9]d INFO replication | grep -q '^master_link_status:up'; do
+    sleep 2
+  done
+}
+restart_pod() {
+  echo "restarting $1"
+  kubectl delete pod "$1" --wait=true
+  wait_ready "$1"
+}
+caught_up() {
+  info="$(cli -h "$1" -p %[9]d INFO replication | tr -d '\r')"
+  offset="$(echo "${info}" | sed -n 's/^master_repl_offset://p')"
+  offsets="$(echo "${info}" | sed -n 's/^slave[0-9]*:.*offset=\([0-9]*\).*/\1/p')"
+  [ -n "${offsets}" ] || return 1
+  for replica in ${offsets}; do
+    if [ $((offset - replica)) -gt %[13]d ]; then
+      echo "replica offset ${replica} lags primary offset ${offset}"
+      return 1
+    fi
+  done
+}
+wait_caught_up() {
+  deadline=$(($(date +%%s) + %[8]d))
+  until caught_up "$1"; do
+    if [ "$(date +%%s)" -ge "${deadline}" ]; then
+      echo "replicas of $1 did not catch up within %[8]ds"
+      return 1
+    fi
+    sleep 1
+  done
+}
+wait_stable() {
+  stable=0
+  until [ "${stable}" -ge %[14]d ]; do
+    sleep 1
+    if [ "$(primary_host)" = "$1" ] && cli -h "$1" -p %[9]d INFO replication | grep -q '^role:master'; then
+      stable=$((stable + 1))
+    else
+      stable=0
+    fi
+  done
+}
+failover() {
+  old="$(primary_host)"
+  wait_caught_up "${old}"
+  sentinel_cli SENTINEL FAILOVER "%[6]s"
+  until [ "$(primary_host)" != "${old}" ]; do
+    sleep 2
+  done
+  until cli -h "$(primary_host)" -p %[9]d INFO replication | grep -q '^role:master'; do
+    sleep 2
+  done
+  wait_stable "$(primary_host)"
+  echo "failed over from ${old} to $(primary_host)"
+}
+rolling_restart() {
+  acquire_lock
+  primary=""
+  for ordinal in $(seq 0 $((%[10]d - 1))); do
+    pod="%[11]s-${ordinal}"
+    if is_pod_host "${pod}" "$(primary_host)"; then
+      primary="${pod}"
+      continue
+    fi
+    if needs_restart "${pod}"; then
+      restart_pod "${pod}"
+      wait_synced "${pod}"
+    fi
+  done
+  if [ -z "${primary}" ] || ! needs_restart "${primary}"; then
+    return 0
+  fi
+  if [ %[10]d -gt 1 ]; then
+    failover
+    restart_pod "${primary}"
+    wait_synced "${primary}"
+  else
+    restart_pod "${primary}"
+  fi
+}
+`
+
+// newRollingRestartScript returns [rollingRestartScript] bound to an instance. The script expects the tooling preamble
+// of [newValkeyToolingScript], kubectl in [toolingBinPath], and the sentinel password in [sentinelPasswordEnv].
+func newRollingRestartScript(name string, spec *InstanceSpec) string {
+	return fmt.Sprintf(
+		rollingRestartScript,
+		toolingBinPath,
+		sentinelPasswordEnv,
+		toolingTLSPath,
+		fmt.Sprintf("%s.%s.svc.cluster.local", name, spec.namespaceName(name)),
+		sentinelPort,
+		sentinelMasterSet,
+		fmt.Sprintf("%s.%s.svc.cluster.local", headlessServiceName(name), spec.namespaceName(name)),
+		rollingRestartTimeout,
+		valkeyPort,
+		spec.Replicas,
+		nodeStatefulSetName(name),
+		spec.namespaceName(name),
+		spec.Upgrade.MaxOffsetLag,
+		spec.Upgrade.StableSeconds,
+		restartLockName(name),
+		restartLockUnlocked,
+	)
+}
+
+// restartsPods reports whether any Job of the instance restarts its Valkey pods, and so needs the restart lock.
+func (spec *InstanceSpec) restartsPods() bool {
+	return spec.managedUpgrade() || spec.reloadsCertificates()
+}
+
+// deployValkeyRestartLock deploys the Lease the Jobs restarting the Valkey pods of an instance take turns holding.
+// The holder is only ever changed by the Jobs, so Pulumi ignores it after creating the Lease.
+func deployValkeyRestartLock(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (*coordinationv1.Lease, error) {
+	lease, err := coordinationv1.NewLease(
+		ctx,
+		restartLockName(name),
+		&coordinationv1.LeaseArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(restartLockName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newValkeyInstanceLabels(name),
+			},
+			Spec: &coordinationv1.LeaseSpecArgs{
+				HolderIdentity: pulumi.String(restartLockUnlocked),
+			},
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+		pulumi.IgnoreChanges([]string{"spec"}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// newKubectlInitContainer returns the init container copying kubectl into [toolingBinPath] so that scripts running in
+// the Valkey image can drive the Kubernetes API.
+func newKubectlInitContainer(toolsMount *corev1.VolumeMountArgs) *corev1.ContainerArgs {
+	return &corev1.ContainerArgs{
+		Name:  pulumi.String("kubectl"),
+		Image: pulumi.String(kubectlImage),
+		Command: pulumi.StringArray{
+			pulumi.String("cp"),
+			pulumi.String(kubectlPath),
+			pulumi.String(toolingBinPath + "/kubectl"),
+		},
+		VolumeMounts: corev1.VolumeMountArray{toolsMount},
+	}
+}
+
+// deployValkeyToolingRBAC deploys a ServiceAccount for the tooling [component] of an instance together with a Role
+// granting [rules] in the instance namespace.
+func deployValkeyToolingRBAC(
+	ctx *pulumi.Context,
+	name string,
+	component string,
+	namespace *corev1.Namespace,
+	rules rbacv1.PolicyRuleArray,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	accountName := toolingServiceAccountName(name, component)
+	metadata := &metav1.ObjectMetaArgs{
+		Name:      pulumi.String(accountName),
+		Namespace: namespace.Metadata.Name(),
+		Labels:    newValkeyToolingLabels(name, component),
+	}
+
+	account, err := corev1.NewServiceAccount(
+		ctx,
+		accountName,
+		&corev1.ServiceAccountArgs{
+			Metadata: metadata,
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := rbacv1.NewRole(
+		ctx,
+		accountName,
+		&rbacv1.RoleArgs{
+			Metadata: metadata,
+			Rules:    rules,
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	binding, err := rbacv1.NewRoleBinding(
+		ctx,
+		accountName,
+		&rbacv1.RoleBindingArgs{
+			Metadata: metadata,
+			RoleRef: &rbacv1.RoleRefArgs{
+				ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+				Kind:     pulumi.String("Role"),
+				Name:     pulumi.String(accountName),
+			},
+			Subjects: rbacv1.SubjectArray{
+				&rbacv1.SubjectArgs{
+					Kind:      pulumi.String("ServiceAccount"),
+					Name:      pulumi.String(accountName),
+					Namespace: namespace.Metadata.Name(),
+				},
+			},
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn([]pulumi.Resource{account, role}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []pulumi.Resource{account, role, binding}, nil
+}
+
+// newRollingRestartPolicyRules returns the permissions [rollingRestartScript] needs to restart the Valkey pods and to
+// hold the restart lock.
+func newRollingRestartPolicyRules(name string) rbacv1.PolicyRuleArray {
+	return rbacv1.PolicyRuleArray{
+		&rbacv1.PolicyRuleArgs{
+			ApiGroups:     pulumi.StringArray{pulumi.String("coordination.k8s.io")},
+			Resources:     pulumi.StringArray{pulumi.String("leases")},
+			ResourceNames: pulumi.StringArray{pulumi.String(restartLockName(name))},
+			Verbs:         pulumi.StringArray{pulumi.String("get"), pulumi.String("patch")},
+		},
+		&rbacv1.PolicyRuleArgs{
+			ApiGroups: pulumi.StringArray{pulumi.String("")},
+			Resources: pulumi.StringArray{pulumi.String("pods")},
+			Verbs: pulumi.StringArray{
+				pulumi.String("get"),
+				pulumi.String("list"),
+				pulumi.String("watch"),
+				pulumi.String("patch"),
+				pulumi.String("delete"),
+			},
+		},
+	}
+}
+
+// restartLockName returns the name of the Lease guarding the restarts of an instance's Valkey pods.
+func restartLockName(name string) string {
+	return fmt.Sprintf("%s-restart-lock", name)
+}
+
+// toolingServiceAccountName returns the name of the ServiceAccount used by a tooling component of an instance.
+func toolingServiceAccountName(name string, component string) string {
+	return fmt.Sprintf("%s-%s", name, component)
+}
+
+// nodeStatefulSetName returns the name of the StatefulSet the bitnami chart renders for a [ModeReplication] instance.
+func nodeStatefulSetName(name string) string {
+	return fmt.Sprintf("%s-node", name)
+}
@@ -0,0 +1,92 @@
+package valkey
+
+import (
+	"fmt"
+)
+
+// ErrInvalidSentinelConfig is returned when the sentinels of a [ModeReplication] instance couldn't fail over.
+var ErrInvalidSentinelConfig = fmt.Errorf("invalid sentinel config")
+
+// minDownAfterMilliseconds keeps the primary from being marked down between two of the pings sentinel sends every
+// second.
+const minDownAfterMilliseconds = 1000
+
+// SentinelConfig configures the sentinels running next to every Valkey pod of a [ModeReplication] instance.
+// SEE: https://valkey.io/topics/sentinel/
+type SentinelConfig struct {
+	// Quorum is the number of sentinels that have to agree the primary is down. It defaults to a majority of the
+	// sentinels.
+	Quorum int `json:"quorum"`
+	// DownAfterMilliseconds is how long the primary has to be unreachable before a sentinel considers it down.
+	DownAfterMilliseconds int `json:"downAfterMilliseconds"`
+	// FailoverTimeout is how many milliseconds a failover may take before sentinel retries it.
+	FailoverTimeout int `json:"failoverTimeout"`
+	// ParallelSyncs is how many replicas resync from a newly promoted primary at the same time. Replicas are unavailable
+	// while they resync.
+	ParallelSyncs int `json:"parallelSyncs"`
+}
+
+// newDefaultSentinelConfig returns the sentinel config used when the stack config doesn't override it. The timings
+// match the chart's defaults.
+func newDefaultSentinelConfig() *SentinelConfig {
+	return &SentinelConfig{
+		DownAfterMilliseconds: 60000,
+		FailoverTimeout:       180000,
+		ParallelSyncs:         1,
+	}
+}
+
+// quorum returns the configured quorum, or a majority of the [replicas] sentinels.
+func (s *SentinelConfig) quorum(replicas int) int {
+	if s.Quorum == 0 {
+		return majority(replicas)
+	}
+	return s.Quorum
+}
+
+// validate checks the quorum against the number of sentinels, one per Valkey pod. A failover is authorized by a
+// majority of the sentinels, so an instance of two can't fail over once either pod is gone. A quorum of every sentinel
+// would keep the PodDisruptionBudget from letting any pod be drained, so it has to leave one sentinel out. Parallel
+// syncs can't exceed the number of replicas the primary has.
+func (s *SentinelConfig) validate(replicas int) error {
+	maxQuorum := max(1, replicas-1)
+	if s.Quorum < 0 || s.quorum(replicas) > maxQuorum {
+		return fmt.Errorf("%w: quorum must be between 1 and %d", ErrInvalidSentinelConfig, maxQuorum)
+	}
+	if replicas == 2 {
+		return fmt.Errorf("%w: 2 replicas can't keep a sentinel majority, use 1 or at least 3", ErrInvalidSentinelConfig)
+	}
+	if s.DownAfterMilliseconds < minDownAfterMilliseconds {
+		return fmt.Errorf(
+			"%w: downAfterMilliseconds must be at least %d",
+			ErrInvalidSentinelConfig,
+			minDownAfterMilliseconds,
+		)
+	}
+	if s.FailoverTimeout < s.DownAfterMilliseconds {
+		return fmt.Errorf(
+			"%w: failoverTimeout must be at least downAfterMilliseconds (%d)",
+			ErrInvalidSentinelConfig,
+			s.DownAfterMilliseconds,
+		)
+	}
+	if s.ParallelSyncs < 1 || s.ParallelSyncs > max(1, replicas-1) {
+		return fmt.Errorf(
+			"%w: parallelSyncs must be between 1 and the %d replicas of the primary",
+			ErrInvalidSentinelConfig,
+			max(1, replicas-1),
+		)
+	}
+	return nil
+}
+
+// minAvailable returns how many sentinels have to stay up for a failover to still be possible: the quorum has to
+// agree the primary is down and a majority has to authorize the failover.
+func (s *SentinelConfig) minAvailable(replicas int) int {
+	return max(s.quorum(replicas), majority(replicas))
+}
+
+// majority returns the smallest number greater than half of [n].
+func majority(n int) int {
+	return n/2 + 1
+}
@@ -0,0 +1,115 @@
+package valkey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSentinelConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int
+		modify   func(sentinel *SentinelConfig)
+		want     error
+	}{
+		{
+			name:     "defaults with a single pod",
+			replicas: 1,
+			modify:   func(*SentinelConfig) {},
+		},
+		{
+			name:     "defaults with three pods",
+			replicas: 3,
+			modify:   func(*SentinelConfig) {},
+		},
+		{
+			name:     "two pods",
+			replicas: 2,
+			modify:   func(*SentinelConfig) {},
+			want:     ErrInvalidSentinelConfig,
+		},
+		{
+			name:     "quorum leaving one sentinel out",
+			replicas: 5,
+			modify:   func(sentinel *SentinelConfig) { sentinel.Quorum = 4 },
+		},
+		{
+			name:     "quorum of every sentinel",
+			replicas: 3,
+			modify:   func(sentinel *SentinelConfig) { sentinel.Quorum = 3 },
+			want:     ErrInvalidSentinelConfig,
+		},
+		{
+			name:     "negative quorum",
+			replicas: 3,
+			modify:   func(sentinel *SentinelConfig) { sentinel.Quorum = -1 },
+			want:     ErrInvalidSentinelConfig,
+		},
+		{
+			name:     "down after less than a ping interval",
+			replicas: 3,
+			modify:   func(sentinel *SentinelConfig) { sentinel.DownAfterMilliseconds = 500 },
+			want:     ErrInvalidSentinelConfig,
+		},
+		{
+			name:     "failover timeout shorter than down after",
+			replicas: 3,
+			modify: func(sentinel *SentinelConfig) {
+				sentinel.DownAfterMilliseconds = 5000
+				sentinel.FailoverTimeout = 4000
+			},
+			want: ErrInvalidSentinelConfig,
+		},
+		{
+			name:     "parallel syncs of every replica",
+			replicas: 3,
+			modify:   func(sentinel *SentinelConfig) { sentinel.ParallelSyncs = 2 },
+		},
+		{
+			name:     "more parallel syncs than replicas",
+			replicas: 3,
+			modify:   func(sentinel *SentinelConfig) { sentinel.ParallelSyncs = 3 },
+			want:     ErrInvalidSentinelConfig,
+		},
+		{
+			name:     "no parallel syncs",
+			replicas: 3,
+			modify:   func(sentinel *SentinelConfig) { sentinel.ParallelSyncs = 0 },
+			want:     ErrInvalidSentinelConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sentinel := newDefaultSentinelConfig()
+			tt.modify(sentinel)
+			err := sentinel.validate(tt.replicas)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validate(%d) = %v, want %v", tt.replicas, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSentinelConfigMinAvailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		quorum   int
+		replicas int
+		want     int
+	}{
+		{name: "single pod", replicas: 1, want: 1},
+		{name: "majority of three", replicas: 3, want: 2},
+		{name: "majority of five", replicas: 5, want: 3},
+		{name: "quorum above the majority", quorum: 4, replicas: 5, want: 4},
+		{name: "quorum below the majority", quorum: 1, replicas: 5, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sentinel := &SentinelConfig{Quorum: tt.quorum}
+			got := sentinel.minAvailable(tt.replicas)
+			if got != tt.want {
+				t.Errorf("minAvailable(%d) = %d, want %d", tt.replicas, got, tt.want)
+			}
+		})
+	}
+}
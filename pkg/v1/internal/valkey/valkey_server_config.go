@@ -0,0 +1,334 @@
+package valkey
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidServerConfig is returned when a server setting is out of range or conflicts with another one.
+var ErrInvalidServerConfig = fmt.Errorf("invalid server config")
+
+const (
+	maxIOThreads = 256
+	// keyspaceEventFlags are the characters accepted by `notify-keyspace-events`.
+	keyspaceEventFlags = "KEg$lshzxetmdnA"
+)
+
+// outputBufferClasses lists the client classes of `client-output-buffer-limit` in the order they are rendered.
+var outputBufferClasses = []string{"normal", "replica", "pubsub"}
+
+// configTemplate renders the server configuration passed to the charts. ACL users are rendered separately by
+// [aclTemplate].
+const configTemplate = `
+# ACL users are loaded from a separately mounted file so that changing them does not change this configuration.
+# SEE: https://valkey.io/topics/acl/#use-an-external-acl-file
+aclfile {{ .ACLFilePath }}
+{{- with .ModulePaths }}
+
+# SEE: https://valkey.io/topics/modules-intro/
+{{- range $path := . }}
+loadmodule {{ $path }}
+{{- end }}
+{{- end }}
+{{- if .TLS.CertificateUsers }}
+
+# Authenticate clients as the ACL user named by the common name of their certificate.
+tls-auth-clients-user CN
+{{- end }}
+
+# SEE: https://valkey.io/docs/topics/persistence.html
+{{- with .Persistence }}
+{{- if .AOFEnabled }}
+appendonly yes
+appendfsync {{ .AppendFsync }}
+{{- else }}
+appendonly no
+{{- end }}
+{{ if .RDBEnabled }}
+# Unless specified otherwise, by default the server will save the DB:
+#   * After 3600 seconds (an hour) if at least 1 change was performed
+#   * After 300 seconds (5 minutes) if at least 100 changes were performed
+#   * After 60 seconds if at least 10000 changes were performed
+save 3600 1 300 100 60 10000
+{{- else }}
+save ""
+{{- end }}
+{{- end }}
+
+# The memory limit of the container leaves headroom above maxmemory for buffers, forks, and fragmentation.
+# SEE: https://valkey.io/topics/lru-cache/
+{{- with .Sizing }}
+maxmemory {{ .MaxMemory }}
+maxmemory-policy {{ .MaxMemoryPolicy }}
+{{- end }}
+
+# SEE: https://github.com/valkey-io/valkey/blob/unstable/valkey.conf
+{{- range $directive := .Server.Directives }}
+{{ $directive.Name }} {{ $directive.Value }}
+{{- end }}
+`
+
+// ServerConfig holds the typed server settings of an instance. The defaults match the ones Valkey ships with.
+type ServerConfig struct {
+	// Timeout closes connections idle for that many seconds. 0 disables it.
+	Timeout      int `json:"timeout"`
+	TCPKeepalive int `json:"tcpKeepalive"`
+	IOThreads    int `json:"ioThreads"`
+	// LatencyTrackingPercentiles are reported by `INFO latencystats` when LatencyTracking is enabled. Valkey reports
+	// the p50, p99, and p99.9 unless they are set.
+	LatencyTracking            bool      `json:"latencyTracking"`
+	LatencyTrackingPercentiles []float64 `json:"latencyTrackingPercentiles"`
+	// LatencyMonitorThreshold records events slower than that many milliseconds. 0 disables the latency monitor.
+	LatencyMonitorThreshold int             `json:"latencyMonitorThreshold"`
+	Lazyfree                *LazyfreeConfig `json:"lazyfree"`
+	// ClientOutputBufferLimits is keyed by client class: normal, replica, or pubsub.
+	ClientOutputBufferLimits map[string]*OutputBufferLimit `json:"clientOutputBufferLimits"`
+	// KeyspaceNotifications are the `notify-keyspace-events` flags. Empty disables notifications.
+	KeyspaceNotifications string         `json:"keyspaceNotifications"`
+	Slowlog               *SlowlogConfig `json:"slowlog"`
+}
+
+// LazyfreeConfig selects which deletions free memory in a background thread.
+// SEE: https://valkey.io/topics/lazyfree/
+type LazyfreeConfig struct {
+	LazyEviction     bool `json:"lazyEviction"`
+	LazyExpire       bool `json:"lazyExpire"`
+	LazyServerDel    bool `json:"lazyServerDel"`
+	LazyUserDel      bool `json:"lazyUserDel"`
+	LazyUserFlush    bool `json:"lazyUserFlush"`
+	ReplicaLazyFlush bool `json:"replicaLazyFlush"`
+}
+
+// OutputBufferLimit disconnects clients whose output buffer reaches HardLimit, or stays above SoftLimit for
+// SoftSeconds. Limits are memory quantities such as `64Mi`, and `0` disables a limit.
+type OutputBufferLimit struct {
+	HardLimit   string `json:"hardLimit"`
+	SoftLimit   string `json:"softLimit"`
+	SoftSeconds int    `json:"softSeconds"`
+}
+
+// SlowlogConfig records commands slower than LogSlowerThan microseconds. -1 disables the slowlog.
+type SlowlogConfig struct {
+	LogSlowerThan int `json:"logSlowerThan"`
+	MaxLen        int `json:"maxLen"`
+}
+
+// serverDirective is a single rendered `valkey.conf` line.
+type serverDirective struct {
+	Name  string
+	Value string
+}
+
+// newDefaultServerConfig returns the server settings used when the stack config doesn't override them.
+func newDefaultServerConfig() *ServerConfig {
+	return &ServerConfig{
+		Timeout:                 0,
+		TCPKeepalive:            300,
+		IOThreads:               1,
+		LatencyTracking:         true,
+		LatencyMonitorThreshold: 0,
+		Lazyfree:                newDefaultLazyfreeConfig(),
+		ClientOutputBufferLimits: map[string]*OutputBufferLimit{
+			"normal":  {HardLimit: "0", SoftLimit: "0", SoftSeconds: 0},
+			"replica": {HardLimit: "256Mi", SoftLimit: "64Mi", SoftSeconds: 60},
+			"pubsub":  {HardLimit: "32Mi", SoftLimit: "8Mi", SoftSeconds: 60},
+		},
+		Slowlog: newDefaultSlowlogConfig(),
+	}
+}
+
+// newDefaultLazyfreeConfig returns the lazyfree settings used when the stack config omits them or sets them to null.
+func newDefaultLazyfreeConfig() *LazyfreeConfig {
+	return &LazyfreeConfig{
+		LazyEviction:     true,
+		LazyExpire:       true,
+		LazyServerDel:    true,
+		LazyUserDel:      true,
+		LazyUserFlush:    true,
+		ReplicaLazyFlush: true,
+	}
+}
+
+// newDefaultSlowlogConfig returns the slowlog settings used when the stack config omits them or sets them to null.
+func newDefaultSlowlogConfig() *SlowlogConfig {
+	return &SlowlogConfig{
+		LogSlowerThan: 10000,
+		MaxLen:        128,
+	}
+}
+
+// lazyfree returns the lazyfree settings, falling back to the defaults when the stack config sets them to null.
+func (c *ServerConfig) lazyfree() *LazyfreeConfig {
+	if c.Lazyfree == nil {
+		return newDefaultLazyfreeConfig()
+	}
+	return c.Lazyfree
+}
+
+// slowlog returns the slowlog settings, falling back to the defaults when the stack config sets them to null.
+func (c *ServerConfig) slowlog() *SlowlogConfig {
+	if c.Slowlog == nil {
+		return newDefaultSlowlogConfig()
+	}
+	return c.Slowlog
+}
+
+// validate checks the ranges of the server settings and rejects settings that contradict each other.
+func (c *ServerConfig) validate() error {
+	if c.Timeout < 0 || c.TCPKeepalive < 0 || c.LatencyMonitorThreshold < 0 {
+		return fmt.Errorf("%w: timeouts and thresholds can't be negative", ErrInvalidServerConfig)
+	}
+	if c.IOThreads < 1 || c.IOThreads > maxIOThreads {
+		return fmt.Errorf("%w: ioThreads must be between 1 and %d", ErrInvalidServerConfig, maxIOThreads)
+	}
+	if !c.LatencyTracking && len(c.LatencyTrackingPercentiles) > 0 {
+		return fmt.Errorf("%w: latencyTrackingPercentiles require latencyTracking", ErrInvalidServerConfig)
+	}
+	for _, percentile := range c.LatencyTrackingPercentiles {
+		if percentile < 0 || percentile > 100 {
+			return fmt.Errorf("%w: latency percentile %v is not between 0 and 100", ErrInvalidServerConfig, percentile)
+		}
+	}
+	for class, limit := range c.ClientOutputBufferLimits {
+		if limit == nil {
+			return fmt.Errorf("%w: the %s client output buffer limit can't be null", ErrInvalidServerConfig, class)
+		}
+		err := limit.validate(class)
+		if err != nil {
+			return err
+		}
+	}
+	err := validateKeyspaceNotifications(c.KeyspaceNotifications)
+	if err != nil {
+		return err
+	}
+	slowlog := c.slowlog()
+	if slowlog.LogSlowerThan < -1 || slowlog.MaxLen < 0 {
+		return fmt.Errorf("%w: slowlog thresholds must be at least -1 and lengths at least 0", ErrInvalidServerConfig)
+	}
+	return nil
+}
+
+// validate checks that the class is known and that the soft limit is below the hard limit.
+func (l *OutputBufferLimit) validate(class string) error {
+	if !slices.Contains(outputBufferClasses, class) {
+		return fmt.Errorf("%w: unknown client output buffer class %q", ErrInvalidServerConfig, class)
+	}
+	hard, err := parseBufferLimit(l.HardLimit)
+	if err != nil {
+		return err
+	}
+	soft, err := parseBufferLimit(l.SoftLimit)
+	if err != nil {
+		return err
+	}
+	if hard > 0 && soft > hard {
+		return fmt.Errorf("%w: the %s soft limit exceeds its hard limit", ErrInvalidServerConfig, class)
+	}
+	if l.SoftSeconds < 0 || (soft == 0 && l.SoftSeconds > 0) {
+		return fmt.Errorf("%w: the %s soft seconds require a soft limit", ErrInvalidServerConfig, class)
+	}
+	return nil
+}
+
+// validateKeyspaceNotifications checks the flags and that event types are published to at least one of the keyspace
+// (K) or keyevent (E) channels, without which Valkey publishes nothing.
+func validateKeyspaceNotifications(flags string) error {
+	if flags == "" {
+		return nil
+	}
+	for _, flag := range flags {
+		if !strings.ContainsRune(keyspaceEventFlags, flag) {
+			return fmt.Errorf("%w: unknown keyspace notification flag %q", ErrInvalidServerConfig, flag)
+		}
+	}
+	if !strings.ContainsAny(flags, "KE") {
+		return fmt.Errorf("%w: keyspace notifications need the K or E flag", ErrInvalidServerConfig)
+	}
+	if strings.Trim(flags, "KE") == "" {
+		return fmt.Errorf("%w: keyspace notifications need at least one event type", ErrInvalidServerConfig)
+	}
+	return nil
+}
+
+// Directives returns the server settings as `valkey.conf` lines in a fixed order, so that the rendered configuration,
+// and with it the Helm release, only changes when a setting does.
+func (c *ServerConfig) Directives() []serverDirective {
+	directives := []serverDirective{
+		{"timeout", strconv.Itoa(c.Timeout)},
+		{"tcp-keepalive", strconv.Itoa(c.TCPKeepalive)},
+		{"io-threads", strconv.Itoa(c.IOThreads)},
+		{"latency-tracking", yesNo(c.LatencyTracking)},
+	}
+	if len(c.LatencyTrackingPercentiles) > 0 {
+		percentiles := make([]string, 0, len(c.LatencyTrackingPercentiles))
+		for _, percentile := range c.LatencyTrackingPercentiles {
+			percentiles = append(percentiles, strconv.FormatFloat(percentile, 'f', -1, 64))
+		}
+		directives = append(directives, serverDirective{"latency-tracking-info-percentiles", strings.Join(percentiles, " ")})
+	}
+	lazyfree := c.lazyfree()
+	directives = append(directives,
+		serverDirective{"latency-monitor-threshold", strconv.Itoa(c.LatencyMonitorThreshold)},
+		serverDirective{"lazyfree-lazy-eviction", yesNo(lazyfree.LazyEviction)},
+		serverDirective{"lazyfree-lazy-expire", yesNo(lazyfree.LazyExpire)},
+		serverDirective{"lazyfree-lazy-server-del", yesNo(lazyfree.LazyServerDel)},
+		serverDirective{"lazyfree-lazy-user-del", yesNo(lazyfree.LazyUserDel)},
+		serverDirective{"lazyfree-lazy-user-flush", yesNo(lazyfree.LazyUserFlush)},
+		serverDirective{"replica-lazy-flush", yesNo(lazyfree.ReplicaLazyFlush)},
+	)
+	for _, class := range outputBufferClasses {
+		limit, ok := c.ClientOutputBufferLimits[class]
+		if !ok {
+			continue
+		}
+		hard, _ := parseBufferLimit(limit.HardLimit)
+		soft, _ := parseBufferLimit(limit.SoftLimit)
+		directives = append(directives, serverDirective{
+			"client-output-buffer-limit",
+			fmt.Sprintf("%s %d %d %d", class, hard, soft, limit.SoftSeconds),
+		})
+	}
+	slowlog := c.slowlog()
+	directives = append(directives,
+		serverDirective{"notify-keyspace-events", strconv.Quote(c.KeyspaceNotifications)},
+		serverDirective{"slowlog-log-slower-than", strconv.Itoa(slowlog.LogSlowerThan)},
+		serverDirective{"slowlog-max-len", strconv.Itoa(slowlog.MaxLen)},
+	)
+	return directives
+}
+
+// ACLFilePath exposes [aclFilePath] to the config template.
+func (spec *InstanceSpec) ACLFilePath() string {
+	return aclFilePath
+}
+
+// newValkeyCommonConfig uses text templating to compose the contents of the Valkey server configuration as a string.
+// ACL users are not part of it, see [newValkeyUserACL].
+func newValkeyCommonConfig(
+	spec *InstanceSpec,
+) (string, error) {
+	return executeValkeyTemplate("valkey.conf", configTemplate, spec)
+}
+
+// parseBufferLimit returns the number of bytes of an output buffer limit, where `0` or an empty limit disables it.
+func parseBufferLimit(limit string) (int64, error) {
+	if limit == "" || limit == "0" {
+		return 0, nil
+	}
+	bytes, err := parseMemoryQuantity(limit)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidServerConfig, err)
+	}
+	return bytes, nil
+}
+
+// yesNo renders a boolean setting.
+func yesNo(enabled bool) string {
+	if enabled {
+		return "yes"
+	}
+	return "no"
+}
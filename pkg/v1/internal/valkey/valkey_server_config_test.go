@@ -0,0 +1,168 @@
+package valkey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestServerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(server *ServerConfig)
+		want   error
+	}{
+		{
+			name:   "defaults",
+			modify: func(*ServerConfig) {},
+		},
+		{
+			name:   "negative timeout",
+			modify: func(server *ServerConfig) { server.Timeout = -1 },
+			want:   ErrInvalidServerConfig,
+		},
+		{
+			name:   "no io threads",
+			modify: func(server *ServerConfig) { server.IOThreads = 0 },
+			want:   ErrInvalidServerConfig,
+		},
+		{
+			name:   "too many io threads",
+			modify: func(server *ServerConfig) { server.IOThreads = maxIOThreads + 1 },
+			want:   ErrInvalidServerConfig,
+		},
+		{
+			name:   "latency percentiles",
+			modify: func(server *ServerConfig) { server.LatencyTrackingPercentiles = []float64{50, 99.9} },
+		},
+		{
+			name: "latency percentiles without latency tracking",
+			modify: func(server *ServerConfig) {
+				server.LatencyTracking = false
+				server.LatencyTrackingPercentiles = []float64{99}
+			},
+			want: ErrInvalidServerConfig,
+		},
+		{
+			name:   "latency percentile above 100",
+			modify: func(server *ServerConfig) { server.LatencyTrackingPercentiles = []float64{100.1} },
+			want:   ErrInvalidServerConfig,
+		},
+		{
+			name:   "null output buffer limit",
+			modify: func(server *ServerConfig) { server.ClientOutputBufferLimits["pubsub"] = nil },
+			want:   ErrInvalidServerConfig,
+		},
+		{
+			name:   "keyspace notifications",
+			modify: func(server *ServerConfig) { server.KeyspaceNotifications = "Ex" },
+		},
+		{
+			name:   "invalid keyspace notifications",
+			modify: func(server *ServerConfig) { server.KeyspaceNotifications = "x" },
+			want:   ErrInvalidServerConfig,
+		},
+		{
+			name:   "null slowlog falls back to the defaults",
+			modify: func(server *ServerConfig) { server.Slowlog = nil },
+		},
+		{
+			name:   "disabled slowlog",
+			modify: func(server *ServerConfig) { server.Slowlog = &SlowlogConfig{LogSlowerThan: -1} },
+		},
+		{
+			name:   "slowlog threshold below -1",
+			modify: func(server *ServerConfig) { server.Slowlog = &SlowlogConfig{LogSlowerThan: -2} },
+			want:   ErrInvalidServerConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newDefaultServerConfig()
+			tt.modify(server)
+			err := server.validate()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputBufferLimitValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		class string
+		limit *OutputBufferLimit
+		want  error
+	}{
+		{
+			name:  "unlimited",
+			class: "normal",
+			limit: &OutputBufferLimit{HardLimit: "0", SoftLimit: "0"},
+		},
+		{
+			name:  "soft limit below hard limit",
+			class: "replica",
+			limit: &OutputBufferLimit{HardLimit: "256Mi", SoftLimit: "64Mi", SoftSeconds: 60},
+		},
+		{
+			name:  "soft limit without hard limit",
+			class: "pubsub",
+			limit: &OutputBufferLimit{HardLimit: "0", SoftLimit: "8Mi", SoftSeconds: 60},
+		},
+		{
+			name:  "unknown class",
+			class: "slave",
+			limit: &OutputBufferLimit{HardLimit: "0", SoftLimit: "0"},
+			want:  ErrInvalidServerConfig,
+		},
+		{
+			name:  "soft limit above hard limit",
+			class: "replica",
+			limit: &OutputBufferLimit{HardLimit: "64Mi", SoftLimit: "256Mi", SoftSeconds: 60},
+			want:  ErrInvalidServerConfig,
+		},
+		{
+			name:  "soft seconds without soft limit",
+			class: "pubsub",
+			limit: &OutputBufferLimit{HardLimit: "32Mi", SoftLimit: "0", SoftSeconds: 60},
+			want:  ErrInvalidServerConfig,
+		},
+		{
+			name:  "negative soft seconds",
+			class: "pubsub",
+			limit: &OutputBufferLimit{HardLimit: "32Mi", SoftLimit: "8Mi", SoftSeconds: -1},
+			want:  ErrInvalidServerConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limit.validate(tt.class)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validate(%q) = %v, want %v", tt.class, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateKeyspaceNotifications(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags string
+		want  error
+	}{
+		{name: "disabled", flags: ""},
+		{name: "expired keyevents", flags: "Ex"},
+		{name: "every event on both channels", flags: "KEA"},
+		{name: "unknown flag", flags: "Eq", want: ErrInvalidServerConfig},
+		{name: "no channel", flags: "x", want: ErrInvalidServerConfig},
+		{name: "no event type", flags: "KE", want: ErrInvalidServerConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKeyspaceNotifications(tt.flags)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validateKeyspaceNotifications(%q) = %v, want %v", tt.flags, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package valkey
+
+import (
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	helmv4 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v4"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	shardedChartName = "valkey-cluster"
+	shardedChartRepo = "oci://registry-1.docker.io/bitnamicharts/valkey-cluster"
+	// shardedChartVersion is the latest `valkey-cluster` release as of [chartVersion], so that both modes run the
+	// same Valkey release. The two charts are versioned separately.
+	shardedChartVersion    = "3.0.14"
+	shardedImageRepository = "docker.io/bitnami/valkey-cluster"
+)
+
+// newValkeyShardedHelmChartArgs constructs the Helm chart values needed to deploy a sharded Valkey Cluster to k8s. The
+// release reuses the TLS certificate, ACL Secret, and reloader sidecar of the replication mode, and overrides the full
+// name so that the Services match the certificate's DNS names.
+func newValkeyShardedHelmChartArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	configContent string,
+) *helmv4.ChartArgs {
+	persistence, retention := newValkeyPersistenceValues(spec.Persistence)
+	chartArgs := &helmv4.ChartArgs{
+		Chart:     pulumi.String(shardedChartRepo),
+		Namespace: namespace.Metadata.Name(),
+		Version:   pulumi.String(shardedChartVersion),
+		Values: pulumi.Map{
+			"fullnameOverride": pulumi.String(name),
+			"image": pulumi.Map{
+				"digest": pulumi.String(spec.Sharding.ImageDigest),
+			},
+			"usePassword": pulumi.Bool(true),
+			"password":    pulumi.ToSecret(pulumi.String(spec.DefaultUserCredentials)),
+			// NetworkPolicies are managed by deployValkeyNetworkPolicies instead of the chart.
+			"networkPolicy": pulumi.Map{
+				"enabled": pulumi.Bool(false),
+			},
+			"cluster": pulumi.Map{
+				"nodes":    pulumi.Int(spec.Sharding.Nodes()),
+				"replicas": pulumi.Int(spec.Sharding.ReplicasPerShard),
+			},
+			"valkey": pulumi.Map{
+				"configmap":                 pulumi.String(configContent),
+				"resources":                 spec.Sizing.ValkeyResources().values(),
+				"initContainers":            newValkeyInitContainers(name, spec),
+				"extraVolumes":              newValkeyExtraVolumes(name, spec),
+				"extraVolumeMounts":         newValkeyExtraVolumeMounts(spec),
+				"sidecars":                  newValkeySidecars(name, spec),
+				"podAntiAffinityPreset":     pulumi.String(spec.Placement.AntiAffinity),
+				"topologySpreadConstraints": newValkeyTopologySpreadConstraints(name, spec),
+				"nodeSelector":              newValkeyNodeSelectorValues(spec),
+				"tolerations":               newValkeyTolerationValues(spec),
+			},
+			// The PodDisruptionBudget is managed by deployValkeyPodDisruptionBudget instead of the chart.
+			"pdb": pulumi.Map{
+				"create": pulumi.Bool(false),
+			},
+			"persistence":                          persistence,
+			"persistentVolumeClaimRetentionPolicy": retention,
+			"tls": pulumi.Map{
+				"enabled":         pulumi.Bool(true),
+				"authClients":     pulumi.Bool(spec.TLS.AuthClients),
+				"existingSecret":  pulumi.String(clusterCertificateSecretName(name)),
+				"certFilename":    pulumi.String("tls.crt"),
+				"certKeyFilename": pulumi.String("tls.key"),
+				"certCAFilename":  pulumi.String("ca.crt"),
+			},
+		},
+	}
+	if spec.Metrics.Enabled {
+		chartArgs.Values.(pulumi.Map)["metrics"] = newValkeyMetricsValues(name, spec)
+	}
+	return chartArgs
+}
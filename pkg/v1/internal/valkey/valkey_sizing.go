@@ -0,0 +1,172 @@
+package valkey
+
+import (
+	"fmt"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"slices"
+)
+
+// ErrInvalidSizingConfig is returned when the sizing of a Valkey instance is inconsistent.
+var ErrInvalidSizingConfig = fmt.Errorf("invalid sizing config")
+
+// SizingProfile selects the resources of the Valkey and sentinel containers.
+type SizingProfile string
+
+const (
+	SizingSmall  SizingProfile = "small"
+	SizingMedium SizingProfile = "medium"
+	SizingLarge  SizingProfile = "large"
+	// SizingCustom uses the resources set in [SizingConfig] instead of a predefined profile.
+	SizingCustom SizingProfile = "custom"
+)
+
+// maxMemoryPolicies lists the `maxmemory-policy` values Valkey accepts.
+// SEE: https://valkey.io/topics/lru-cache/
+var maxMemoryPolicies = []string{
+	"noeviction",
+	"allkeys-lru",
+	"allkeys-lfu",
+	"allkeys-random",
+	"volatile-lru",
+	"volatile-lfu",
+	"volatile-random",
+	"volatile-ttl",
+}
+
+// ResourcesConfig holds the CPU and memory requests and limits of a container.
+type ResourcesConfig struct {
+	CPURequest    string `json:"cpuRequest"`
+	CPULimit      string `json:"cpuLimit"`
+	MemoryRequest string `json:"memoryRequest"`
+	MemoryLimit   string `json:"memoryLimit"`
+}
+
+// SizingConfig sizes the containers of an instance and derives `maxmemory` from the Valkey memory limit, leaving the
+// remainder of the limit for replication buffers, forks during RDB and AOF rewrites, and fragmentation.
+//
+// Any pod of a [ModeReplication] instance can be promoted, so the primary and the replicas share the Valkey resources.
+type SizingConfig struct {
+	Profile SizingProfile `json:"profile"`
+	// Valkey and Sentinel are only read when Profile is [SizingCustom].
+	Valkey   *ResourcesConfig `json:"valkey"`
+	Sentinel *ResourcesConfig `json:"sentinel"`
+	// MaxMemoryRatio is the fraction of the Valkey memory limit used as `maxmemory`.
+	MaxMemoryRatio  float64 `json:"maxMemoryRatio"`
+	MaxMemoryPolicy string  `json:"maxMemoryPolicy"`
+}
+
+// sizingProfiles holds the Valkey and sentinel resources of every predefined profile.
+var sizingProfiles = map[SizingProfile][2]*ResourcesConfig{
+	SizingSmall: {
+		{CPURequest: "250m", CPULimit: "500m", MemoryRequest: "512Mi", MemoryLimit: "512Mi"},
+		{CPURequest: "50m", CPULimit: "100m", MemoryRequest: "64Mi", MemoryLimit: "128Mi"},
+	},
+	SizingMedium: {
+		{CPURequest: "500m", CPULimit: "1", MemoryRequest: "2Gi", MemoryLimit: "2Gi"},
+		{CPURequest: "100m", CPULimit: "250m", MemoryRequest: "128Mi", MemoryLimit: "256Mi"},
+	},
+	SizingLarge: {
+		{CPURequest: "1", CPULimit: "2", MemoryRequest: "8Gi", MemoryLimit: "8Gi"},
+		{CPURequest: "250m", CPULimit: "500m", MemoryRequest: "256Mi", MemoryLimit: "512Mi"},
+	},
+}
+
+// newDefaultSizingConfig returns the sizing used when the stack config doesn't override it.
+func newDefaultSizingConfig() *SizingConfig {
+	return &SizingConfig{
+		Profile:         SizingMedium,
+		MaxMemoryRatio:  0.75,
+		MaxMemoryPolicy: "noeviction",
+	}
+}
+
+// validate checks that the profile is known, that custom resources are complete, and that `maxmemory` leaves headroom
+// below the memory limit.
+func (s *SizingConfig) validate() error {
+	if s.Profile == SizingCustom {
+		for _, resources := range []*ResourcesConfig{s.Valkey, s.Sentinel} {
+			if resources == nil || resources.CPURequest == "" || resources.CPULimit == "" ||
+				resources.MemoryRequest == "" || resources.MemoryLimit == "" {
+				return fmt.Errorf(
+					"%w: the %s profile requires valkey and sentinel requests and limits",
+					ErrInvalidSizingConfig,
+					SizingCustom,
+				)
+			}
+		}
+	} else if _, ok := sizingProfiles[s.Profile]; !ok {
+		return fmt.Errorf("%w: unknown profile %q", ErrInvalidSizingConfig, s.Profile)
+	}
+	if s.MaxMemoryRatio <= 0 || s.MaxMemoryRatio > 0.9 {
+		return fmt.Errorf("%w: maxMemoryRatio must be greater than 0 and at most 0.9", ErrInvalidSizingConfig)
+	}
+	if !slices.Contains(maxMemoryPolicies, s.MaxMemoryPolicy) {
+		return fmt.Errorf("%w: unknown maxMemoryPolicy %q", ErrInvalidSizingConfig, s.MaxMemoryPolicy)
+	}
+	_, err := s.MaxMemory()
+	return err
+}
+
+// ValkeyResources returns the resources of the Valkey containers.
+func (s *SizingConfig) ValkeyResources() *ResourcesConfig {
+	if s.Profile == SizingCustom {
+		return s.Valkey
+	}
+	return sizingProfiles[s.Profile][0]
+}
+
+// SentinelResources returns the resources of the sentinel containers.
+func (s *SizingConfig) SentinelResources() *ResourcesConfig {
+	if s.Profile == SizingCustom {
+		return s.Sentinel
+	}
+	return sizingProfiles[s.Profile][1]
+}
+
+// MaxMemory returns the `maxmemory` in bytes derived from the Valkey memory limit.
+func (s *SizingConfig) MaxMemory() (int64, error) {
+	limit, err := parseMemoryQuantity(s.ValkeyResources().MemoryLimit)
+	if err != nil {
+		return 0, err
+	}
+	return int64(float64(limit) * s.MaxMemoryRatio), nil
+}
+
+// values returns the resources as Helm chart values.
+func (r *ResourcesConfig) values() pulumi.Map {
+	return pulumi.Map{
+		"requests": pulumi.Map{
+			"cpu":    pulumi.String(r.CPURequest),
+			"memory": pulumi.String(r.MemoryRequest),
+		},
+		"limits": pulumi.Map{
+			"cpu":    pulumi.String(r.CPULimit),
+			"memory": pulumi.String(r.MemoryLimit),
+		},
+	}
+}
+
+// requirements returns the resources as the requirements of a container.
+func (r *ResourcesConfig) requirements() *corev1.ResourceRequirementsArgs {
+	return &corev1.ResourceRequirementsArgs{
+		Requests: pulumi.StringMap{
+			"cpu":    pulumi.String(r.CPURequest),
+			"memory": pulumi.String(r.MemoryRequest),
+		},
+		Limits: pulumi.StringMap{
+			"cpu":    pulumi.String(r.CPULimit),
+			"memory": pulumi.String(r.MemoryLimit),
+		},
+	}
+}
+
+// parseMemoryQuantity returns the number of bytes of a Kubernetes memory quantity such as `512Mi` or `2G`.
+func parseMemoryQuantity(quantity string) (int64, error) {
+	parsed, err := resource.ParseQuantity(quantity)
+	if err != nil || parsed.Sign() <= 0 {
+		return 0, fmt.Errorf("%w: invalid memory quantity %q", ErrInvalidSizingConfig, quantity)
+	}
+	return parsed.Value(), nil
+}
@@ -0,0 +1,152 @@
+package valkey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMemoryQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity string
+		want     int64
+		wantErr  error
+	}{
+		{name: "binary suffix", quantity: "512Mi", want: 512 << 20},
+		{name: "decimal suffix", quantity: "2G", want: 2_000_000_000},
+		{name: "plain bytes", quantity: "1024", want: 1024},
+		{name: "fractional", quantity: "1.5Gi", want: 3 << 29},
+		{name: "empty", quantity: "", wantErr: ErrInvalidSizingConfig},
+		{name: "unknown suffix", quantity: "2GB", wantErr: ErrInvalidSizingConfig},
+		{name: "zero", quantity: "0", wantErr: ErrInvalidSizingConfig},
+		{name: "negative", quantity: "-1Gi", wantErr: ErrInvalidSizingConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMemoryQuantity(tt.quantity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseMemoryQuantity(%q) error = %v, want %v", tt.quantity, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseMemoryQuantity(%q) = %d, want %d", tt.quantity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSizingConfigMaxMemory(t *testing.T) {
+	tests := []struct {
+		name   string
+		sizing *SizingConfig
+		want   int64
+	}{
+		{
+			name:   "small profile",
+			sizing: &SizingConfig{Profile: SizingSmall, MaxMemoryRatio: 0.75},
+			want:   384 << 20,
+		},
+		{
+			name:   "medium profile",
+			sizing: &SizingConfig{Profile: SizingMedium, MaxMemoryRatio: 0.5},
+			want:   1 << 30,
+		},
+		{
+			name: "custom profile",
+			sizing: &SizingConfig{
+				Profile:        SizingCustom,
+				Valkey:         &ResourcesConfig{MemoryLimit: "1Gi"},
+				MaxMemoryRatio: 0.25,
+			},
+			want: 256 << 20,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.sizing.MaxMemory()
+			if err != nil {
+				t.Fatalf("MaxMemory() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("MaxMemory() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSizingConfigValidate(t *testing.T) {
+	custom := &ResourcesConfig{CPURequest: "100m", CPULimit: "200m", MemoryRequest: "256Mi", MemoryLimit: "256Mi"}
+	tests := []struct {
+		name   string
+		modify func(sizing *SizingConfig)
+		want   error
+	}{
+		{
+			name:   "defaults",
+			modify: func(*SizingConfig) {},
+		},
+		{
+			name: "custom profile",
+			modify: func(sizing *SizingConfig) {
+				sizing.Profile = SizingCustom
+				sizing.Valkey = custom
+				sizing.Sentinel = custom
+			},
+		},
+		{
+			name: "custom profile without sentinel resources",
+			modify: func(sizing *SizingConfig) {
+				sizing.Profile = SizingCustom
+				sizing.Valkey = custom
+			},
+			want: ErrInvalidSizingConfig,
+		},
+		{
+			name: "custom profile with an incomplete resource",
+			modify: func(sizing *SizingConfig) {
+				sizing.Profile = SizingCustom
+				sizing.Valkey = &ResourcesConfig{CPURequest: "100m", CPULimit: "200m", MemoryLimit: "256Mi"}
+				sizing.Sentinel = custom
+			},
+			want: ErrInvalidSizingConfig,
+		},
+		{
+			name: "custom profile with an invalid memory limit",
+			modify: func(sizing *SizingConfig) {
+				sizing.Profile = SizingCustom
+				sizing.Valkey = &ResourcesConfig{CPURequest: "1", CPULimit: "1", MemoryRequest: "1G", MemoryLimit: "1GB"}
+				sizing.Sentinel = custom
+			},
+			want: ErrInvalidSizingConfig,
+		},
+		{
+			name:   "unknown profile",
+			modify: func(sizing *SizingConfig) { sizing.Profile = "huge" },
+			want:   ErrInvalidSizingConfig,
+		},
+		{
+			name:   "ratio without headroom",
+			modify: func(sizing *SizingConfig) { sizing.MaxMemoryRatio = 0.95 },
+			want:   ErrInvalidSizingConfig,
+		},
+		{
+			name:   "zero ratio",
+			modify: func(sizing *SizingConfig) { sizing.MaxMemoryRatio = 0 },
+			want:   ErrInvalidSizingConfig,
+		},
+		{
+			name:   "unknown eviction policy",
+			modify: func(sizing *SizingConfig) { sizing.MaxMemoryPolicy = "allkeys-fifo" },
+			want:   ErrInvalidSizingConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sizing := newDefaultSizingConfig()
+			tt.modify(sizing)
+			err := sizing.validate()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
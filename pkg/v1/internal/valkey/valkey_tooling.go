@@ -0,0 +1,147 @@
+package valkey
+
+import (
+	"fmt"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"strings"
+)
+
+const (
+	// toolingLabel marks the Job and CronJob pods of an instance. The NetworkPolicies let them reach the Valkey pods.
+	toolingLabel      = "valkey.fjarm.io/tooling"
+	toolingTLSPath    = "/certs"
+	toolingTLSVolume  = "tls"
+	toolingTmpPath    = "/tmp"
+	toolingTmpVolume  = "tmp"
+	toolingBinPath    = "/tools"
+	toolingBinVolume  = "tools"
+	valkeyCLIAuthEnv  = "VALKEYCLI_AUTH"
+	valkeyCLIPreamble = `set -euo pipefail
+cli() {
+  valkey-cli --tls --cacert "%[1]s/ca.crt" --cert "%[1]s/tls.crt" --key "%[1]s/tls.key" --user "%[2]s" "$@"
+}
+`
+)
+
+// newValkeyToolingLabels returns the labels of the pods an instance runs for maintenance tasks such as backups.
+func newValkeyToolingLabels(name string, component string) pulumi.StringMap {
+	labels := newValkeyInstanceLabels(name)
+	labels["app.kubernetes.io/component"] = pulumi.String(component)
+	labels[toolingLabel] = pulumi.String(name)
+	return labels
+}
+
+// revisionedJobName returns the name of a Job that runs again whenever [revision] changes. The Job controller labels
+// its pods with the Job name, which is limited to [maxNameLength] characters, so the prefix is truncated to fit.
+func revisionedJobName(prefix string, revision string) string {
+	prefix = prefix[:min(len(prefix), maxNameLength-len(revision)-1)]
+	return fmt.Sprintf("%s-%s", strings.TrimRight(prefix, "-"), revision)
+}
+
+// newValkeyToolingScript prefixes a bash script with strict mode and a `cli` function running valkey-cli over TLS as
+// the supplied ACL user. The password is read by valkey-cli from [valkeyCLIAuthEnv].
+func newValkeyToolingScript(username string, script string) string {
+	return fmt.Sprintf(valkeyCLIPreamble, toolingTLSPath, username) + script
+}
+
+// newValkeyToolingContainer returns a container running a bash script with valkey-cli from the Valkey image. The
+// instance's certificate is mounted for TLS and client authentication.
+func newValkeyToolingContainer(
+	name string,
+	containerName string,
+	script string,
+	env corev1.EnvVarArray,
+	extraMounts ...corev1.VolumeMountInput,
+) *corev1.ContainerArgs {
+	return &corev1.ContainerArgs{
+		Name:    pulumi.String(containerName),
+		Image:   pulumi.String(fmt.Sprintf("%s@%s", imageRepository, imageDigest)),
+		Command: pulumi.StringArray{pulumi.String("/bin/bash"), pulumi.String("-c")},
+		Args:    pulumi.StringArray{pulumi.String(script)},
+		Env:     env,
+		SecurityContext: &corev1.SecurityContextArgs{
+			RunAsUser:                pulumi.Int(valkeyRunAsUser),
+			RunAsNonRoot:             pulumi.Bool(true),
+			AllowPrivilegeEscalation: pulumi.Bool(false),
+		},
+		VolumeMounts: append(corev1.VolumeMountArray{
+			&corev1.VolumeMountArgs{
+				Name:      pulumi.String(toolingTLSVolume),
+				MountPath: pulumi.String(toolingTLSPath),
+				ReadOnly:  pulumi.Bool(true),
+			},
+			&corev1.VolumeMountArgs{
+				Name:      pulumi.String(toolingTmpVolume),
+				MountPath: pulumi.String(toolingTmpPath),
+			},
+		}, extraMounts...),
+	}
+}
+
+// newValkeyToolingVolumes returns the volumes mounted by [newValkeyToolingContainer].
+func newValkeyToolingVolumes(name string) corev1.VolumeArray {
+	return corev1.VolumeArray{
+		&corev1.VolumeArgs{
+			Name: pulumi.String(toolingTLSVolume),
+			Secret: &corev1.SecretVolumeSourceArgs{
+				SecretName: pulumi.String(clusterCertificateSecretName(name)),
+			},
+		},
+		&corev1.VolumeArgs{
+			Name:     pulumi.String(toolingTmpVolume),
+			EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+		},
+	}
+}
+
+// newValkeyToolingPodSecurityContext returns the pod security context of the tooling pods. The group matches the
+// bitnami images so that mounted Secrets and volumes are readable.
+func newValkeyToolingPodSecurityContext() *corev1.PodSecurityContextArgs {
+	return &corev1.PodSecurityContextArgs{
+		FsGroup: pulumi.Int(valkeyRunAsUser),
+	}
+}
+
+// newSecretKeyEnvVar returns an environment variable populated from a Secret key.
+func newSecretKeyEnvVar(envName string, secretName string, key string) *corev1.EnvVarArgs {
+	return &corev1.EnvVarArgs{
+		Name: pulumi.String(envName),
+		ValueFrom: &corev1.EnvVarSourceArgs{
+			SecretKeyRef: &corev1.SecretKeySelectorArgs{
+				Name: pulumi.String(secretName),
+				Key:  pulumi.String(key),
+			},
+		},
+	}
+}
+
+// newEnvVar returns an environment variable with a literal value.
+func newEnvVar(envName string, value string) *corev1.EnvVarArgs {
+	return &corev1.EnvVarArgs{
+		Name:  pulumi.String(envName),
+		Value: pulumi.String(value),
+	}
+}
+
+// nodeHostname returns the stable DNS name of the Valkey pod with the supplied ordinal.
+func nodeHostname(name string, spec *InstanceSpec, ordinal int) string {
+	pod := fmt.Sprintf("%s-%d", nodeStatefulSetName(name), ordinal)
+	if spec.Mode == ModeCluster {
+		pod = fmt.Sprintf("%s-%d", name, ordinal)
+	}
+	return fmt.Sprintf("%s.%s.%s.svc.cluster.local", pod, headlessServiceName(name), spec.namespaceName(name))
+}
+
+// nodeHostnames returns the stable DNS names of every Valkey pod of the instance.
+func nodeHostnames(name string, spec *InstanceSpec) []string {
+	nodes := spec.Replicas
+	if spec.Mode == ModeCluster {
+		nodes = spec.Sharding.Nodes()
+	}
+	hostnames := make([]string, 0, nodes)
+	for i := 0; i < nodes; i++ {
+		hostnames = append(hostnames, nodeHostname(name, spec, i))
+	}
+	return hostnames
+}
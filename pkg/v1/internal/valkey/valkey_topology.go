@@ -0,0 +1,213 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	policyv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/policy/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ErrInvalidPlacementConfig is returned when the placement of a Valkey instance's pods is unknown, or a toleration
+// can't match any taint.
+var ErrInvalidPlacementConfig = fmt.Errorf("invalid placement config")
+
+const (
+	hostnameTopologyKey = "kubernetes.io/hostname"
+	zoneTopologyKey     = "topology.kubernetes.io/zone"
+	tolerationEqual     = "Equal"
+	tolerationExists    = "Exists"
+	taintNoExecute      = "NoExecute"
+)
+
+// AntiAffinity selects whether the pods of an instance may share a node.
+type AntiAffinity string
+
+const (
+	// AntiAffinitySoft prefers spreading the pods over nodes but still schedules them when there are too few nodes.
+	AntiAffinitySoft AntiAffinity = "soft"
+	// AntiAffinityHard never schedules two pods of an instance on the same node, so the cluster needs a schedulable node
+	// for every pod.
+	AntiAffinityHard AntiAffinity = "hard"
+)
+
+// PlacementConfig spreads the pods of an instance over nodes and zones. In [ModeReplication] the sentinels run in the
+// Valkey pods, so spreading the data pods spreads the sentinels as well.
+//
+// NodeSelector and Tolerations pin the Valkey pods to a dedicated node pool, e.g. the labelled and tainted workers
+// of `kind-config.yaml`. Tooling Jobs and the proxy aren't pinned, so they don't take up room on the pool.
+type PlacementConfig struct {
+	AntiAffinity AntiAffinity      `json:"antiAffinity"`
+	NodeSelector map[string]string `json:"nodeSelector"`
+	Tolerations  []*Toleration     `json:"tolerations"`
+}
+
+// Toleration lets the Valkey pods schedule onto nodes with a matching taint. The fields follow the Kubernetes
+// toleration.
+type Toleration struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	Effect   string `json:"effect"`
+	// TolerationSeconds bounds how long a pod stays on a node after a matching `NoExecute` taint is added.
+	TolerationSeconds *int `json:"tolerationSeconds"`
+}
+
+// newDefaultPlacementConfig returns the placement used when the stack config doesn't override it. The pods are spread
+// over nodes where possible but still scheduled when an instance has more pods than there are schedulable nodes, e.g.
+// the 6 pods of a default [ModeCluster] instance on the 3 data pool workers of `kind-config.yaml`.
+func newDefaultPlacementConfig() *PlacementConfig {
+	return &PlacementConfig{
+		AntiAffinity: AntiAffinitySoft,
+	}
+}
+
+// validate checks that the anti-affinity is known, that node selector labels are named, and that the tolerations are
+// well-formed.
+func (p *PlacementConfig) validate() error {
+	switch p.AntiAffinity {
+	case AntiAffinitySoft, AntiAffinityHard:
+	default:
+		return fmt.Errorf("%w: unknown antiAffinity %q", ErrInvalidPlacementConfig, p.AntiAffinity)
+	}
+	for key := range p.NodeSelector {
+		if key == "" {
+			return fmt.Errorf("%w: node selector labels need a key", ErrInvalidPlacementConfig)
+		}
+	}
+	for _, toleration := range p.Tolerations {
+		err := toleration.validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks the operator and effect of the toleration, and the fields they require.
+func (t *Toleration) validate() error {
+	switch t.Operator {
+	case "", tolerationEqual:
+		if t.Key == "" {
+			return fmt.Errorf("%w: an Equal toleration needs a key", ErrInvalidPlacementConfig)
+		}
+	case tolerationExists:
+		if t.Value != "" {
+			return fmt.Errorf("%w: an Exists toleration of %q can't have a value", ErrInvalidPlacementConfig, t.Key)
+		}
+	default:
+		return fmt.Errorf("%w: unknown toleration operator %q", ErrInvalidPlacementConfig, t.Operator)
+	}
+	switch t.Effect {
+	case "", "NoSchedule", "PreferNoSchedule", taintNoExecute:
+	default:
+		return fmt.Errorf("%w: unknown toleration effect %q", ErrInvalidPlacementConfig, t.Effect)
+	}
+	if t.TolerationSeconds != nil && t.Effect != taintNoExecute {
+		return fmt.Errorf("%w: tolerationSeconds requires the NoExecute effect", ErrInvalidPlacementConfig)
+	}
+	return nil
+}
+
+// newValkeyNodeSelectorValues returns the chart values of the node selector of the Valkey pods.
+func newValkeyNodeSelectorValues(spec *InstanceSpec) pulumi.StringMap {
+	return pulumi.ToStringMap(spec.Placement.NodeSelector)
+}
+
+// newValkeyTolerationValues returns the chart values of the tolerations of the Valkey pods. Unset fields are left out
+// so that Kubernetes applies its defaults.
+func newValkeyTolerationValues(spec *InstanceSpec) pulumi.Array {
+	tolerations := pulumi.Array{}
+	for _, toleration := range spec.Placement.Tolerations {
+		values := pulumi.Map{}
+		for field, value := range map[string]string{
+			"key":      toleration.Key,
+			"operator": toleration.Operator,
+			"value":    toleration.Value,
+			"effect":   toleration.Effect,
+		} {
+			if value != "" {
+				values[field] = pulumi.String(value)
+			}
+		}
+		if toleration.TolerationSeconds != nil {
+			values["tolerationSeconds"] = pulumi.Int(*toleration.TolerationSeconds)
+		}
+		tolerations = append(tolerations, values)
+	}
+	return tolerations
+}
+
+// newValkeyTopologySpreadConstraints returns the constraints spreading the pods of an instance evenly over nodes, and
+// over zones where the nodes have any.
+func newValkeyTopologySpreadConstraints(name string, spec *InstanceSpec) pulumi.Array {
+	nodes := "ScheduleAnyway"
+	if spec.Placement.AntiAffinity == AntiAffinityHard {
+		nodes = "DoNotSchedule"
+	}
+	selector := pulumi.Map{
+		"matchLabels": newValkeyPodSelectorLabels(name, spec),
+	}
+	return pulumi.Array{
+		pulumi.Map{
+			"maxSkew":           pulumi.Int(1),
+			"topologyKey":       pulumi.String(hostnameTopologyKey),
+			"whenUnsatisfiable": pulumi.String(nodes),
+			"labelSelector":     selector,
+		},
+		pulumi.Map{
+			"maxSkew":           pulumi.Int(1),
+			"topologyKey":       pulumi.String(zoneTopologyKey),
+			"whenUnsatisfiable": pulumi.String("ScheduleAnyway"),
+			"labelSelector":     selector,
+		},
+	}
+}
+
+// deployValkeyPodDisruptionBudget deploys the PodDisruptionBudget of an instance's pods. In [ModeReplication] enough
+// pods stay up for the sentinels to keep their quorum, while in [ModeCluster] a single pod may be disrupted at a time
+// so that no shard loses its primary and replica together. The charts' own budgets are disabled in favor of this one.
+func deployValkeyPodDisruptionBudget(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) (*policyv1.PodDisruptionBudget, error) {
+	budget := &policyv1.PodDisruptionBudgetSpecArgs{
+		Selector: &metav1.LabelSelectorArgs{
+			MatchLabels: newValkeyPodSelectorLabels(name, spec),
+		},
+	}
+	if spec.Mode == ModeCluster {
+		budget.MaxUnavailable = pulumi.Int(1)
+	} else {
+		budget.MinAvailable = pulumi.Int(spec.Sentinel.minAvailable(spec.Replicas))
+	}
+
+	pdb, err := policyv1.NewPodDisruptionBudget(
+		ctx,
+		podDisruptionBudgetName(name),
+		&policyv1.PodDisruptionBudgetArgs{
+			Metadata: &metav1.ObjectMetaArgs{
+				Name:      pulumi.String(podDisruptionBudgetName(name)),
+				Namespace: namespace.Metadata.Name(),
+				Labels:    newValkeyInstanceLabels(name),
+			},
+			Spec: budget,
+		},
+		pulumi.Provider(provider),
+		pulumi.DependsOn(deps),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pdb, nil
+}
+
+// podDisruptionBudgetName returns the name of an instance's PodDisruptionBudget.
+func podDisruptionBudgetName(name string) string {
+	return fmt.Sprintf("%s-pdb", name)
+}
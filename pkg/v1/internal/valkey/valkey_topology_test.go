@@ -0,0 +1,118 @@
+package valkey
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlacementConfigValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		placement *PlacementConfig
+		want      error
+	}{
+		{
+			name:      "defaults",
+			placement: newDefaultPlacementConfig(),
+		},
+		{
+			name: "data pool",
+			placement: &PlacementConfig{
+				AntiAffinity: AntiAffinityHard,
+				NodeSelector: map[string]string{"node.fjarm.io/pool": "data"},
+				Tolerations: []*Toleration{
+					{Key: "node.fjarm.io/pool", Operator: tolerationEqual, Value: "data", Effect: "NoSchedule"},
+				},
+			},
+		},
+		{
+			name:      "unknown anti-affinity",
+			placement: &PlacementConfig{AntiAffinity: "strict"},
+			want:      ErrInvalidPlacementConfig,
+		},
+		{
+			name: "node selector without a key",
+			placement: &PlacementConfig{
+				AntiAffinity: AntiAffinitySoft,
+				NodeSelector: map[string]string{"": "data"},
+			},
+			want: ErrInvalidPlacementConfig,
+		},
+		{
+			name: "invalid toleration",
+			placement: &PlacementConfig{
+				AntiAffinity: AntiAffinitySoft,
+				Tolerations:  []*Toleration{{Operator: "In"}},
+			},
+			want: ErrInvalidPlacementConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.placement.validate()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTolerationValidate(t *testing.T) {
+	seconds := 300
+	tests := []struct {
+		name       string
+		toleration *Toleration
+		want       error
+	}{
+		{
+			name:       "equal with the default operator",
+			toleration: &Toleration{Key: "node.fjarm.io/pool", Value: "data"},
+		},
+		{
+			name:       "equal without a key",
+			toleration: &Toleration{Operator: tolerationEqual, Value: "data"},
+			want:       ErrInvalidPlacementConfig,
+		},
+		{
+			name:       "exists every taint",
+			toleration: &Toleration{Operator: tolerationExists},
+		},
+		{
+			name:       "exists with a value",
+			toleration: &Toleration{Key: "node.fjarm.io/pool", Operator: tolerationExists, Value: "data"},
+			want:       ErrInvalidPlacementConfig,
+		},
+		{
+			name:       "unknown effect",
+			toleration: &Toleration{Key: "node.fjarm.io/pool", Value: "data", Effect: "NoEvict"},
+			want:       ErrInvalidPlacementConfig,
+		},
+		{
+			name: "toleration seconds of a no execute taint",
+			toleration: &Toleration{
+				Key:               "node.kubernetes.io/unreachable",
+				Operator:          tolerationExists,
+				Effect:            taintNoExecute,
+				TolerationSeconds: &seconds,
+			},
+		},
+		{
+			name: "toleration seconds of a no schedule taint",
+			toleration: &Toleration{
+				Key:               "node.fjarm.io/pool",
+				Value:             "data",
+				Effect:            "NoSchedule",
+				TolerationSeconds: &seconds,
+			},
+			want: ErrInvalidPlacementConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.toleration.validate()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
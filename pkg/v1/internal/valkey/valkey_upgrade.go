@@ -0,0 +1,225 @@
+package valkey
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ErrInvalidUpgradeConfig is returned when the managed upgrade settings are out of range.
+var ErrInvalidUpgradeConfig = fmt.Errorf("invalid upgrade config")
+
+const (
+	upgradeComponent  = "upgrade"
+	upgradeHashLength = 10
+	// upgradeJobDeadline bounds the whole upgrade, which restarts every pod and waits for each one to resync.
+	upgradeJobDeadline = 3600
+)
+
+// upgradeScript rolls the Valkey pods onto the StatefulSet's latest revision once the chart has been updated. The
+// StatefulSet uses the `OnDelete` update strategy, so Kubernetes never restarts a pod on its own and the pods are
+// restarted by [rollingRestartScript] instead, which fails the primary over before restarting it. Pods already
+// running the latest revision are skipped, which makes the Job a no-op when nothing in the pod template changed.
+const upgradeScript = `%[1]s
+generation() {
+  kubectl get statefulset "%[2]s" -o jsonpath='{.metadata.generation}'
+}
+until [ "$(kubectl get statefulset "%[2]s" -o jsonpath='{.status.observedGeneration}')" = "$(generation)" ]; do
+  sleep 2
+done
+revision="$(kubectl get statefulset "%[2]s" -o jsonpath='{.status.updateRevision}')"
+needs_restart() {
+  [ "$(kubectl get pod "$1" -o jsonpath='{.metadata.labels.controller-revision-hash}')" != "${revision}" ]
+}
+for ordinal in $(seq 0 $((%[3]d - 1))); do
+  wait_ready "%[2]s-${ordinal}"
+done
+rolling_restart
+echo "every pod runs revision ${revision}"
+`
+
+// UpgradeConfig controls how chart and image updates reach the Valkey pods of a [ModeReplication] instance. Managed
+// upgrades are the default: they restart the replicas first and fail the primary over to a caught-up replica before
+// restarting it, so writes are only unavailable for the duration of a single failover. Turning them off leaves the
+// rollout to the StatefulSet controller, which restarts the pods in reverse ordinal order regardless of which one is
+// the primary. It has no effect in [ModeCluster].
+type UpgradeConfig struct {
+	// Managed rolls the pods with [deployValkeyUpgrade] instead of the StatefulSet controller.
+	Managed bool `json:"managed"`
+	// MaxOffsetLag is how many bytes a replica's replication offset may trail the primary's before failing over.
+	MaxOffsetLag int64 `json:"maxOffsetLag"`
+	// StableSeconds is how long a new primary has to keep the master role before the old one is restarted.
+	StableSeconds int `json:"stableSeconds"`
+}
+
+// newDefaultUpgradeConfig returns the upgrade config used when the stack config doesn't override it.
+func newDefaultUpgradeConfig() *UpgradeConfig {
+	return &UpgradeConfig{
+		Managed:       true,
+		MaxOffsetLag:  1 << 20,
+		StableSeconds: 10,
+	}
+}
+
+// validate checks that the lag and stabilisation window aren't negative.
+func (u *UpgradeConfig) validate() error {
+	if u.MaxOffsetLag < 0 {
+		return fmt.Errorf("%w: maxOffsetLag can't be negative", ErrInvalidUpgradeConfig)
+	}
+	if u.StableSeconds < 0 {
+		return fmt.Errorf("%w: stableSeconds can't be negative", ErrInvalidUpgradeConfig)
+	}
+	return nil
+}
+
+// managedUpgrade reports whether the pods of the instance are upgraded by [deployValkeyUpgrade] instead of the
+// StatefulSet controller.
+func (spec *InstanceSpec) managedUpgrade() bool {
+	return spec.Mode == ModeReplication && spec.Upgrade.Managed
+}
+
+// newValkeyUpdateStrategyValues returns the StatefulSet update strategy of the chart. Managed upgrades turn off the
+// controller's rolling update, and tell Pulumi not to wait for a rollout that only [upgradeScript] performs.
+func newValkeyUpdateStrategyValues(spec *InstanceSpec) (pulumi.Map, pulumi.Map) {
+	if !spec.managedUpgrade() {
+		return pulumi.Map{"type": pulumi.String("RollingUpdate")}, pulumi.Map{}
+	}
+	return pulumi.Map{"type": pulumi.String("OnDelete")}, pulumi.Map{"pulumi.com/skipAwait": pulumi.String("true")}
+}
+
+// deployValkeyUpgrade deploys the Job rolling the Valkey pods onto the chart's latest revision, along with the
+// ServiceAccount it uses to restart them. The Job is named after a hash of everything rendered into the chart, so it
+// reruns whenever the chart, the image, or the instance spec changes.
+func deployValkeyUpgrade(
+	ctx *pulumi.Context,
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	configContent string,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	rules := append(newRollingRestartPolicyRules(name), &rbacv1.PolicyRuleArgs{
+		ApiGroups:     pulumi.StringArray{pulumi.String("apps")},
+		Resources:     pulumi.StringArray{pulumi.String("statefulsets")},
+		ResourceNames: pulumi.StringArray{pulumi.String(nodeStatefulSetName(name))},
+		Verbs:         pulumi.StringArray{pulumi.String("get")},
+	})
+	rbac, err := deployValkeyToolingRBAC(
+		ctx,
+		name,
+		upgradeComponent,
+		namespace,
+		rules,
+		provider,
+		deps,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := newValkeyUpgradeJobArgs(name, namespace, spec, configContent)
+	if err != nil {
+		return nil, err
+	}
+	// Pulumi waits for the Job to complete, which may take as long as its deadline.
+	timeout := fmt.Sprintf("%ds", upgradeJobDeadline)
+	job, err := batchv1.NewJob(
+		ctx,
+		fmt.Sprintf("%s-%s", name, upgradeComponent),
+		args,
+		pulumi.Provider(provider),
+		pulumi.DependsOn(append(deps, rbac...)),
+		pulumi.Timeouts(&pulumi.CustomTimeouts{Create: timeout, Update: timeout}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return append(rbac, job), nil
+}
+
+// newValkeyUpgradeJobArgs returns the Job running [upgradeScript].
+func newValkeyUpgradeJobArgs(
+	name string,
+	namespace *corev1.Namespace,
+	spec *InstanceSpec,
+	configContent string,
+) (*batchv1.JobArgs, error) {
+	revision, err := newValkeyUpgradeRevision(spec, configContent)
+	if err != nil {
+		return nil, err
+	}
+	script := newValkeyToolingScript(operatorUsername, fmt.Sprintf(
+		upgradeScript,
+		newRollingRestartScript(name, spec),
+		nodeStatefulSetName(name),
+		spec.Replicas,
+	))
+	labels := newValkeyToolingLabels(name, upgradeComponent)
+	toolsMount := &corev1.VolumeMountArgs{
+		Name:      pulumi.String(toolingBinVolume),
+		MountPath: pulumi.String(toolingBinPath),
+	}
+
+	return &batchv1.JobArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(revisionedJobName(fmt.Sprintf("%s-%s", name, upgradeComponent), revision)),
+			Namespace: namespace.Metadata.Name(),
+			Labels:    labels,
+		},
+		Spec: &batchv1.JobSpecArgs{
+			ActiveDeadlineSeconds: pulumi.Int(upgradeJobDeadline),
+			BackoffLimit:          pulumi.Int(0),
+			Template: &corev1.PodTemplateSpecArgs{
+				Metadata: &metav1.ObjectMetaArgs{
+					Labels: labels,
+				},
+				Spec: &corev1.PodSpecArgs{
+					ServiceAccountName: pulumi.String(toolingServiceAccountName(name, upgradeComponent)),
+					RestartPolicy:      pulumi.String("Never"),
+					SecurityContext:    newValkeyToolingPodSecurityContext(),
+					InitContainers:     corev1.ContainerArray{newKubectlInitContainer(toolsMount)},
+					Containers: corev1.ContainerArray{
+						newValkeyToolingContainer(
+							name,
+							upgradeComponent,
+							script,
+							corev1.EnvVarArray{
+								newSecretKeyEnvVar(valkeyCLIAuthEnv, aclSecretName(name), operatorSecretKey),
+								newSecretKeyEnvVar(sentinelPasswordEnv, name, sentinelPasswordKey),
+							},
+							toolsMount,
+						),
+					},
+					Volumes: append(
+						newValkeyToolingVolumes(name),
+						&corev1.VolumeArgs{
+							Name:     pulumi.String(toolingBinVolume),
+							EmptyDir: &corev1.EmptyDirVolumeSourceArgs{},
+						},
+					),
+				},
+			},
+		},
+	}, nil
+}
+
+// newValkeyUpgradeRevision returns a short hash of the chart version, image, server config, and instance spec.
+func newValkeyUpgradeRevision(spec *InstanceSpec, configContent string) (string, error) {
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	for _, part := range []string{chartVersion, imageDigest, configContent, string(encoded)} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))[:upgradeHashLength], nil
+}
@@ -0,0 +1,146 @@
+package valkey
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// ErrTemplateParsingError indicates that there was an issue parsing the acls template that resulted in an empty result
+// or a result containing `<nil>`.
+var ErrTemplateParsingError = fmt.Errorf("error parsing ACL file template")
+
+// aclTemplate renders the ACL file loaded through the `aclfile` directive. ACL files only accept `user` lines, so
+// comments must stay out of the template.
+// SEE: https://valkey.io/topics/acl/
+//
+// In [ModeCluster] the `valkey-cluster` chart replicates, probes, and creates the cluster as the default user, so the
+// default user is granted replication and cluster management commands, and the sentinel and replica users are omitted.
+const aclTemplate = `user default on >{{ .DefaultUserCredentials }} allchannels +multi +slaveof +ping +exec +subscribe +config|rewrite +role +publish +info +client|setname +client|kill +script|kill{{ if .DragonflyBackend }} +replicaof{{ end }}{{ if .ClusterMode }} +psync +replconf +sync +cluster +migrate +asking +readonly +dbsize{{ end }}
+{{- if not .ClusterMode }}
+user sentinel-user on >{{ .SentinelUserCredentials }} allchannels +multi +slaveof +ping +exec +subscribe +config|rewrite +role +publish +info +client|setname +client|kill +script|kill
+user replica-user on >{{ .ReplicaUserCredentials }} +psync +replconf +ping{{ if .DragonflyBackend }} +dfly{{ end }}
+{{- end }}
+user {{ .OperatorUsername }} on >{{ .OperatorUserCredentials }} -@ALL +acl|load +function|load +function|list +info +ping
+{{- with .Metrics }}{{ if .Enabled }}
+user {{ .MetricsUsername }} on >{{ .Password }} -@ALL +info +client|list +config|get +ping
+{{- end }}{{ end }}
+{{- with .Backup }}{{ if .Enabled }}
+user {{ .BackupUsername }} on >{{ .Password }} -@ALL +info +ping +sync +psync +replconf
+{{- end }}{{ end }}
+{{- with .Benchmark }}{{ if .Enabled }}
+user {{ .BenchmarkUsername }} on >{{ .Password }} -@ALL {{ range $rule := .ACLRules }}{{ $rule }} {{ end }}
+{{- end }}{{ end }}
+{{- range $index, $user := .Users }}
+user {{ $user.Username }} on {{ range $password := $user.ActivePasswords }}>{{ $password }} {{ end }}-@ALL {{ range $cmd := $user.ACLRules }}{{ $cmd }} {{ end }}
+{{- end }}
+`
+
+// operatorUsername is the ACL user that in-cluster tooling, like the ACL reloader sidecar, authenticates as.
+const operatorUsername = "operator-user"
+
+// DragonflyBackend tells the ACL template to grant the commands Dragonfly replicates with.
+func (spec *InstanceSpec) DragonflyBackend() bool {
+	return spec.Backend == BackendDragonfly
+}
+
+// ClusterMode tells the ACL template to render the users of [ModeCluster].
+func (spec *InstanceSpec) ClusterMode() bool {
+	return spec.Mode == ModeCluster
+}
+
+// OperatorUsername exposes [operatorUsername] to the ACL template.
+func (spec *InstanceSpec) OperatorUsername() string {
+	return operatorUsername
+}
+
+// valkeyUser describes a user in the Valkey ACL file and their allowed commands. All users start with -@ALL by default.
+//
+// NextPassword and RotationPhase drive a zero-downtime password rotation, see [passwordRotationPhase]. The published
+// password is written to a credentials Secret in every namespace listed in ConsumerNamespaces.
+type valkeyUser struct {
+	Username           string                `json:"username"`
+	Password           string                `json:"password"`
+	NextPassword       string                `json:"nextPassword"`
+	RotationPhase      passwordRotationPhase `json:"rotationPhase"`
+	ConsumerNamespaces []string              `json:"consumerNamespaces"`
+	EnabledCommands    []string              `json:"enabledCommands"`
+}
+
+// ActivePasswords returns the passwords Valkey accepts for the user in its current rotation phase.
+func (u *valkeyUser) ActivePasswords() []string {
+	switch u.RotationPhase {
+	case rotationPhaseAdd, rotationPhasePublish:
+		return []string{u.Password, u.NextPassword}
+	case rotationPhaseRetire:
+		return []string{u.NextPassword}
+	default:
+		return []string{u.Password}
+	}
+}
+
+// PublishedPassword returns the password handed to consumers in their credentials Secrets.
+func (u *valkeyUser) PublishedPassword() string {
+	switch u.RotationPhase {
+	case rotationPhasePublish, rotationPhaseRetire:
+		return u.NextPassword
+	default:
+		return u.Password
+	}
+}
+
+// phase returns the rotation phase of the user, substituting [rotationPhaseStable] for an unset phase.
+func (u *valkeyUser) phase() passwordRotationPhase {
+	if u.RotationPhase == "" {
+		return rotationPhaseStable
+	}
+	return u.RotationPhase
+}
+
+// validate checks the passwords of the user, that the rotation phase is known, and that phases other than
+// [rotationPhaseStable] have a next password to rotate to.
+func (u *valkeyUser) validate() error {
+	err := validatePassword("user "+u.Username, u.Password)
+	if err != nil {
+		return err
+	}
+	switch u.phase() {
+	case rotationPhaseStable:
+		return nil
+	case rotationPhaseAdd, rotationPhasePublish, rotationPhaseRetire:
+		if u.NextPassword == "" {
+			return fmt.Errorf("%w: user %s in phase %s", ErrMissingNextPassword, u.Username, u.RotationPhase)
+		}
+		return validatePassword("user "+u.Username, u.NextPassword)
+	default:
+		return fmt.Errorf("%w: user %s has phase %q", ErrInvalidRotationPhase, u.Username, u.RotationPhase)
+	}
+}
+
+// newValkeyUserACL uses text templating to compose the contents of an ACL file as a string.
+func newValkeyUserACL(
+	spec *InstanceSpec,
+) (string, error) {
+	return executeValkeyTemplate("users.acl", aclTemplate, spec)
+}
+
+// executeValkeyTemplate parses and executes the named template against the supplied instance spec.
+func executeValkeyTemplate(name string, text string, spec *InstanceSpec) (string, error) {
+	tmp, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	templateDestination := bytes.NewBuffer(nil)
+	err = tmp.Execute(templateDestination, spec)
+	if err != nil {
+		return "", err
+	}
+
+	contents := templateDestination.String()
+	if contents == "" || contents == "<nil>" {
+		return "", ErrTemplateParsingError
+	}
+
+	return contents, nil
+}
@@ -0,0 +1,58 @@
+package valkey
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// deployValkeyUserCredentialsSecrets publishes the credentials of every user to each of its consumer namespaces. The
+// password written is [valkeyUser.PublishedPassword], so consumers only switch over once the ACL already accepts it.
+func deployValkeyUserCredentialsSecrets(
+	ctx *pulumi.Context,
+	name string,
+	users []*valkeyUser,
+	provider *kubernetes.Provider,
+	deps []pulumi.Resource,
+) ([]pulumi.Resource, error) {
+	var secrets []pulumi.Resource
+	for _, user := range users {
+		for _, consumerNamespace := range user.ConsumerNamespaces {
+			secret, err := corev1.NewSecret(
+				ctx,
+				fmt.Sprintf("%s-%s", consumerNamespace, userCredentialsSecretName(name, user)),
+				newValkeyUserCredentialsSecretArgs(name, user, consumerNamespace),
+				pulumi.Provider(provider),
+				pulumi.DependsOn(deps),
+			)
+			if err != nil {
+				return nil, err
+			}
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets, nil
+}
+
+// newValkeyUserCredentialsSecretArgs returns the corev1.SecretArgs of a consumer credentials Secret.
+func newValkeyUserCredentialsSecretArgs(name string, user *valkeyUser, consumerNamespace string) *corev1.SecretArgs {
+	return &corev1.SecretArgs{
+		Metadata: &metav1.ObjectMetaArgs{
+			Name:      pulumi.String(userCredentialsSecretName(name, user)),
+			Namespace: pulumi.String(consumerNamespace),
+			Labels:    newValkeyInstanceLabels(name),
+		},
+		Type: pulumi.String("Opaque"),
+		StringData: pulumi.ToSecret(pulumi.StringMap{
+			"username": pulumi.String(user.Username),
+			"password": pulumi.String(user.PublishedPassword()),
+		}).(pulumi.StringMapOutput),
+	}
+}
+
+// userCredentialsSecretName returns the name of the credentials Secret published for a user of an instance.
+func userCredentialsSecretName(name string, user *valkeyUser) string {
+	return fmt.Sprintf("%s-%s-credentials", name, user.Username)
+}
@@ -0,0 +1,163 @@
+package valkey
+
+import (
+	internalvalkey "github.com/fjarm/infrastructure/pkg/v1/internal/valkey"
+)
+
+// The settings of an [InstanceSpec].
+
+// ShardingConfig describes the shape of a Valkey Cluster deployed in [ModeCluster].
+type ShardingConfig = internalvalkey.ShardingConfig
+
+// PersistenceConfig describes how a Valkey instance persists data and the PersistentVolumeClaims backing it.
+type PersistenceConfig = internalvalkey.PersistenceConfig
+
+// PersistenceProfile selects which of Valkey's persistence mechanisms are enabled.
+type PersistenceProfile = internalvalkey.PersistenceProfile
+
+const (
+	PersistenceNone      = internalvalkey.PersistenceNone
+	PersistenceRDB       = internalvalkey.PersistenceRDB
+	PersistenceAOF       = internalvalkey.PersistenceAOF
+	PersistenceRDBAndAOF = internalvalkey.PersistenceRDBAndAOF
+)
+
+// AppendFsync is the `appendfsync` policy used when the AOF is enabled.
+type AppendFsync = internalvalkey.AppendFsync
+
+const (
+	AppendFsyncAlways   = internalvalkey.AppendFsyncAlways
+	AppendFsyncEverySec = internalvalkey.AppendFsyncEverySec
+	AppendFsyncNo       = internalvalkey.AppendFsyncNo
+)
+
+// PVCRetentionPolicy is the StatefulSet `persistentVolumeClaimRetentionPolicy` action applied to the data volumes.
+type PVCRetentionPolicy = internalvalkey.PVCRetentionPolicy
+
+const (
+	PVCRetain = internalvalkey.PVCRetain
+	PVCDelete = internalvalkey.PVCDelete
+)
+
+// SizingConfig sizes the containers of an instance and derives `maxmemory` from the Valkey memory limit.
+type SizingConfig = internalvalkey.SizingConfig
+
+// SizingProfile selects the resources of the Valkey and sentinel containers.
+type SizingProfile = internalvalkey.SizingProfile
+
+const (
+	SizingSmall  = internalvalkey.SizingSmall
+	SizingMedium = internalvalkey.SizingMedium
+	SizingLarge  = internalvalkey.SizingLarge
+	SizingCustom = internalvalkey.SizingCustom
+)
+
+// ResourcesConfig holds the CPU and memory requests and limits of a container.
+type ResourcesConfig = internalvalkey.ResourcesConfig
+
+// PlacementConfig spreads the pods of an instance over nodes and zones.
+type PlacementConfig = internalvalkey.PlacementConfig
+
+// AntiAffinity selects whether the pods of an instance may share a node.
+type AntiAffinity = internalvalkey.AntiAffinity
+
+const (
+	AntiAffinitySoft = internalvalkey.AntiAffinitySoft
+	AntiAffinityHard = internalvalkey.AntiAffinityHard
+)
+
+// Toleration lets the Valkey pods schedule onto nodes with a matching taint.
+type Toleration = internalvalkey.Toleration
+
+// SentinelConfig configures the sentinels running next to every Valkey pod of a [ModeReplication] instance.
+type SentinelConfig = internalvalkey.SentinelConfig
+
+// ServerConfig holds the typed server settings of an instance.
+type ServerConfig = internalvalkey.ServerConfig
+
+// LazyfreeConfig selects which deletions free memory in a background thread.
+type LazyfreeConfig = internalvalkey.LazyfreeConfig
+
+// OutputBufferLimit disconnects clients whose output buffer grows too large.
+type OutputBufferLimit = internalvalkey.OutputBufferLimit
+
+// SlowlogConfig records commands slower than LogSlowerThan microseconds.
+type SlowlogConfig = internalvalkey.SlowlogConfig
+
+// TLSConfig controls mutual TLS between clients and an instance.
+type TLSConfig = internalvalkey.TLSConfig
+
+// AllowedClient selects the pods that may connect to an instance.
+type AllowedClient = internalvalkey.AllowedClient
+
+// ExposureConfig exposes the primary and the sentinels of a [ModeReplication] instance outside of the Kubernetes
+// cluster.
+type ExposureConfig = internalvalkey.ExposureConfig
+
+// ExposureType is the type of the Services exposing an instance outside of the Kubernetes cluster.
+type ExposureType = internalvalkey.ExposureType
+
+const (
+	ExposureNone         = internalvalkey.ExposureNone
+	ExposureLoadBalancer = internalvalkey.ExposureLoadBalancer
+	ExposureNodePort     = internalvalkey.ExposureNodePort
+)
+
+// Module is a Valkey module an instance can load.
+type Module = internalvalkey.Module
+
+const (
+	ModuleJSON   = internalvalkey.ModuleJSON
+	ModuleBloom  = internalvalkey.ModuleBloom
+	ModuleSearch = internalvalkey.ModuleSearch
+)
+
+// BackupConfig schedules RDB backups of an instance to an S3-compatible bucket.
+type BackupConfig = internalvalkey.BackupConfig
+
+// RestoreConfig seeds a fresh instance from a snapshot taken by [BackupConfig].
+type RestoreConfig = internalvalkey.RestoreConfig
+
+// MetricsConfig enables the Prometheus exporter of an instance.
+type MetricsConfig = internalvalkey.MetricsConfig
+
+// FunctionsConfig deploys the Valkey Functions libraries found in Directory.
+type FunctionsConfig = internalvalkey.FunctionsConfig
+
+// UpgradeConfig controls how chart and image updates reach the Valkey pods of a [ModeReplication] instance.
+type UpgradeConfig = internalvalkey.UpgradeConfig
+
+// CertReloadConfig restarts the Valkey pods of a [ModeReplication] instance after its certificate is renewed.
+type CertReloadConfig = internalvalkey.CertReloadConfig
+
+// BenchmarkConfig runs valkey-benchmark against an instance.
+type BenchmarkConfig = internalvalkey.BenchmarkConfig
+
+// ProxyConfig puts an Envoy Redis proxy in front of a [ModeReplication] instance.
+type ProxyConfig = internalvalkey.ProxyConfig
+
+// The errors returned when a setting of an [InstanceSpec] is invalid.
+var (
+	ErrInvalidShardingConfig    = internalvalkey.ErrInvalidShardingConfig
+	ErrInvalidPersistenceConfig = internalvalkey.ErrInvalidPersistenceConfig
+	ErrInvalidSizingConfig      = internalvalkey.ErrInvalidSizingConfig
+	ErrInvalidPlacementConfig   = internalvalkey.ErrInvalidPlacementConfig
+	ErrInvalidSentinelConfig    = internalvalkey.ErrInvalidSentinelConfig
+	ErrInvalidServerConfig      = internalvalkey.ErrInvalidServerConfig
+	ErrInvalidTLSConfig         = internalvalkey.ErrInvalidTLSConfig
+	ErrInvalidAllowedClient     = internalvalkey.ErrInvalidAllowedClient
+	ErrInvalidExposureConfig    = internalvalkey.ErrInvalidExposureConfig
+	ErrInvalidModule            = internalvalkey.ErrInvalidModule
+	ErrInvalidBackupConfig      = internalvalkey.ErrInvalidBackupConfig
+	ErrInvalidMetricsConfig     = internalvalkey.ErrInvalidMetricsConfig
+	ErrInvalidFunctionLibrary   = internalvalkey.ErrInvalidFunctionLibrary
+	ErrInvalidUpgradeConfig     = internalvalkey.ErrInvalidUpgradeConfig
+	ErrInvalidCertReloadConfig  = internalvalkey.ErrInvalidCertReloadConfig
+	ErrInvalidBenchmarkConfig   = internalvalkey.ErrInvalidBenchmarkConfig
+	ErrInvalidProxyConfig       = internalvalkey.ErrInvalidProxyConfig
+	ErrInvalidRotationPhase     = internalvalkey.ErrInvalidRotationPhase
+	ErrMissingNextPassword      = internalvalkey.ErrMissingNextPassword
+	ErrInvalidPassword          = internalvalkey.ErrInvalidPassword
+	ErrUnpinnedImage            = internalvalkey.ErrUnpinnedImage
+	ErrTemplateParsingError     = internalvalkey.ErrTemplateParsingError
+)
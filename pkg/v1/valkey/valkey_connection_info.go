@@ -0,0 +1,33 @@
+package valkey
+
+import (
+	internalvalkey "github.com/fjarm/infrastructure/pkg/v1/internal/valkey"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ConnectionInfo is exported as the `<name>Connection` stack output and tells clients how to reach an instance.
+type ConnectionInfo = internalvalkey.ConnectionInfo
+
+// CABundle references the CA certificate clients verify the instance's certificate with.
+type CABundle = internalvalkey.CABundle
+
+// ExternalConnectionInfo is exported as the `<name>ExternalConnection` stack output of an exposed instance.
+type ExternalConnectionInfo = internalvalkey.ExternalConnectionInfo
+
+// BenchmarkReport is exported as the `<name>Benchmark` stack output.
+type BenchmarkReport = internalvalkey.BenchmarkReport
+
+// BenchmarkResult is the throughput and latency distribution of a single valkey-benchmark test.
+type BenchmarkResult = internalvalkey.BenchmarkResult
+
+// LookupConnectionInfo reads the [ConnectionInfo] of the instance called [name] from the stack deploying it. The
+// output resolves to a *ConnectionInfo.
+func LookupConnectionInfo(ref *pulumi.StackReference, name string) pulumi.Output {
+	return internalvalkey.LookupConnectionInfo(ref, name)
+}
+
+// LookupExternalConnectionInfo reads the [ExternalConnectionInfo] of the exposed instance called [name] from the stack
+// deploying it. The output resolves to a *ExternalConnectionInfo.
+func LookupExternalConnectionInfo(ref *pulumi.StackReference, name string) pulumi.Output {
+	return internalvalkey.LookupExternalConnectionInfo(ref, name)
+}
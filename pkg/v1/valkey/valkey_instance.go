@@ -0,0 +1,68 @@
+package valkey
+
+import (
+	internalvalkey "github.com/fjarm/infrastructure/pkg/v1/internal/valkey"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// The implementation lives in the internal valkey package, which the other backends share. This package only exposes
+// what a Pulumi program needs to declare, deploy, and look up instances.
+
+// InstanceSpec describes a single, independently named Valkey instance.
+type InstanceSpec = internalvalkey.InstanceSpec
+
+// InstanceConfig is an entry of the `valkey:instances` stack config list.
+type InstanceConfig = internalvalkey.InstanceConfig
+
+// Backend selects the server an instance runs.
+type Backend = internalvalkey.Backend
+
+const (
+	// BackendValkey deploys the instance with [DeployValkeyCluster].
+	BackendValkey = internalvalkey.BackendValkey
+	// BackendDragonfly deploys the instance with the `dragonfly` package.
+	BackendDragonfly = internalvalkey.BackendDragonfly
+)
+
+// Mode selects how a Valkey instance is topologically deployed.
+type Mode = internalvalkey.Mode
+
+const (
+	// ModeReplication runs a single primary with replicas, supervised by sentinel.
+	ModeReplication = internalvalkey.ModeReplication
+	// ModeCluster runs a sharded Valkey Cluster where every shard has its own primary and replicas.
+	ModeCluster = internalvalkey.ModeCluster
+)
+
+var (
+	// ErrInvalidInstanceConfig is returned when the stack config declares an instance that can't be deployed.
+	ErrInvalidInstanceConfig = internalvalkey.ErrInvalidInstanceConfig
+	// ErrUnknownBackend is returned when an instance selects a backend that doesn't exist, or is deployed with the
+	// package of another backend.
+	ErrUnknownBackend = internalvalkey.ErrUnknownBackend
+	// ErrUnknownMode is returned when a Valkey instance is configured with an unsupported mode.
+	ErrUnknownMode = internalvalkey.ErrUnknownMode
+)
+
+// NewInstanceConfigsFromStackConfig reads the Valkey instances declared in the `valkey:instances` stack config list.
+func NewInstanceConfigsFromStackConfig(ctx *pulumi.Context) ([]*InstanceConfig, error) {
+	return internalvalkey.NewInstanceConfigsFromStackConfig(ctx)
+}
+
+// UsesBackend reports whether any of the instances runs on [backend].
+func UsesBackend(instances []*InstanceConfig, backend Backend) bool {
+	return internalvalkey.UsesBackend(instances, backend)
+}
+
+// DeployValkeyCluster deploys the Valkey instance called [name] described by [spec]. The only supported options are
+// pulumi.DependsOn options.
+func DeployValkeyCluster(
+	ctx *pulumi.Context,
+	provider *kubernetes.Provider,
+	name string,
+	spec *InstanceSpec,
+	opts ...pulumi.ResourceOption,
+) ([]pulumi.Resource, error) {
+	return internalvalkey.DeployValkeyCluster(ctx, provider, name, spec, opts...)
+}
@@ -0,0 +1,20 @@
+#!/usr/bin/env sh
+
+# The valkey:instances stack config holds the passwords of the instances, which must be stored as Pulumi secrets.
+# Generate random ones for the dev stack's instance instead of committing them to Pulumi.dev.yaml.
+
+STACK="dev"
+INSTANCE="valkey:instances[0]"
+
+set_secret() {
+    pulumi config set --stack "${STACK}" --secret --path "${INSTANCE}.$1" "$(openssl rand -hex 16)"
+}
+
+set_secret defaultUserCredentials
+set_secret sentinelUserCredentials
+set_secret replicaUserCredentials
+set_secret operatorUserCredentials
+set_secret "users[0].password"
+set_secret backup.password
+set_secret backup.accessKey
+set_secret backup.secretKey